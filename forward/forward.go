@@ -0,0 +1,165 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package forward implements the signed address claim Sun attaches to the login of every player it dials to a
+backend when Proxy.IpForwarding is enabled. Backends import this package to pull the real address of a player
+back out of the ClientData they receive and to make sure it was really set by the proxy.
+*/
+package forward
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"strings"
+	"time"
+)
+
+/*
+MaxAge is the maximum age of a claim before Verify rejects it, this stops a claim from being reused by
+somebody who managed to get a hold of one.
+*/
+const MaxAge = time.Minute
+
+var (
+	ErrMissing   = errors.New("no forwarding claim present in the client data")
+	ErrMalformed = errors.New("forwarding claim is malformed")
+	ErrSignature = errors.New("forwarding claim signature does not match")
+	ErrIdentity  = errors.New("forwarding claim was issued for another player")
+	ErrTarget    = errors.New("forwarding claim was issued for another backend")
+	ErrExpired   = errors.New("forwarding claim has expired")
+)
+
+/*
+Data is the information the proxy forwards about a player.
+*/
+type Data struct {
+	//Address is the real IP address of the player as seen by the proxy.
+	Address string
+	//Port is the real port of the player as seen by the proxy.
+	Port uint16
+	//XUID is the XBOX Live user ID of the player, it is forwarded because the proxy can't log in with it.
+	XUID string
+	//Identity is the UUID of the player the claim was issued for.
+	Identity string
+	//Target is the address, as host:port, of the backend the claim was issued for. It stops a backend from
+	//replaying a claim it received against another backend that trusts the same key.
+	Target string
+	//Issued is the unix timestamp the claim was issued at.
+	Issued int64
+}
+
+/*
+Sign encodes and signs the data passed with the key, returning the claim as a string.
+*/
+func Sign(data Data, key string) string {
+	payload, _ := json.Marshal(data)
+	enc := base64.RawURLEncoding.EncodeToString(payload)
+	return enc + "." + base64.RawURLEncoding.EncodeToString(signature(enc, key))
+}
+
+/*
+Parse checks the signature of the claim passed with the key and decodes the data in it.
+Parse does not check the age or identity of the claim, use Verify for that.
+*/
+func Parse(claim, key string) (Data, error) {
+	var data Data
+	parts := strings.Split(claim, ".")
+	if len(parts) != 2 {
+		return data, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return data, ErrMalformed
+	}
+	if !hmac.Equal(sig, signature(parts[0], key)) {
+		return data, ErrSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return data, ErrMalformed
+	}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return data, ErrMalformed
+	}
+	return data, nil
+}
+
+/*
+Embed puts the claim passed into the client data so it is sent along with the login of the player.
+
+The claim is carried in PlatformUserID, the only free form field of the client data that neither the client nor
+the login validation of gophertunnel give a meaning to outside of consoles. The value the client sent in it is
+replaced.
+*/
+func Embed(client *login.ClientData, claim string) {
+	client.PlatformUserID = claim
+}
+
+/*
+Verify pulls the claim out of the client data of a player that logged in, checks that it was signed with the
+key, issued for the identity of that player and for the backend at target and isn't older than MaxAge, and returns
+the data in it. Target is the address the backend is known by to the proxy, as host:port.
+*/
+func Verify(client login.ClientData, identity login.IdentityData, key, target string) (Data, error) {
+	if client.PlatformUserID == "" {
+		return Data{}, ErrMissing
+	}
+	data, err := Parse(client.PlatformUserID, key)
+	if err != nil {
+		return data, err
+	}
+	if data.Identity != identity.Identity {
+		return data, ErrIdentity
+	}
+	if data.Target != target {
+		return data, ErrTarget
+	}
+	if time.Since(time.Unix(data.Issued, 0)) > MaxAge {
+		return data, ErrExpired
+	}
+	return data, nil
+}
+
+func signature(payload, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package forward
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	identity := login.IdentityData{Identity: "5a2e8f2c-4d2b-4b7a-9c38-3f7b1f0e6b11", DisplayName: "Steve"}
+	data := Data{Address: "1.2.3.4", Port: 19132, XUID: "2535400000000000", Identity: identity.Identity, Target: "10.0.0.2:19133", Issued: time.Now().Unix()}
+	var client login.ClientData
+	Embed(&client, Sign(data, "key"))
+
+	got, err := Verify(client, identity, "key", data.Target)
+	if err != nil {
+		t.Fatalf("expected claim to verify: %v", err)
+	}
+	if got != data {
+		t.Fatalf("expected %+v, got %+v", data, got)
+	}
+	if _, err := Verify(client, identity, "other key", data.Target); err != ErrSignature {
+		t.Fatalf("expected ErrSignature, got %v", err)
+	}
+	if _, err := Verify(client, login.IdentityData{Identity: "other"}, "key", data.Target); err != ErrIdentity {
+		t.Fatalf("expected ErrIdentity, got %v", err)
+	}
+	if _, err := Verify(client, identity, "key", "10.0.0.3:19133"); err != ErrTarget {
+		t.Fatalf("expected ErrTarget, got %v", err)
+	}
+	data.Issued = time.Now().Add(-MaxAge * 2).Unix()
+	Embed(&client, Sign(data, "key"))
+	if _, err := Verify(client, identity, "key", data.Target); err != ErrExpired {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if _, err := Verify(login.ClientData{}, identity, "key", data.Target); err != ErrMissing {
+		t.Fatalf("expected ErrMissing, got %v", err)
+	}
+}
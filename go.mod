@@ -37,8 +37,12 @@ module github.com/sunproxy/sun
 go 1.14
 
 require (
+	github.com/go-gl/mathgl v1.0.0
+	github.com/google/uuid v1.1.2
 	github.com/pelletier/go-toml v1.8.1
+	github.com/sandertv/go-raknet v1.9.1
 	github.com/sandertv/gophertunnel v1.10.3
 	go.uber.org/atomic v1.7.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -37,9 +37,15 @@ SOFTWARE.
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/sunproxy/sun/sun"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -49,6 +55,31 @@ func main() {
 		_, _ = fmt.Scanln()
 		return
 	}
+	stopped := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := s.Shutdown(ctx); err != nil {
+				log.Println(err)
+			}
+			close(stopped)
+		})
+	}
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		fmt.Println("Stopping Sun!")
+		stop()
+	}()
+	console := sun.NewConsole(s)
+	console.Stop = stop
+	consoleDone := console.RunStdin()
 	fmt.Println("Starting Sun On " + s.Listener.Addr().String() + "!")
 	s.Start()
+	<-stopped
+	//the console restores the terminal when it stops
+	<-consoleDone
 }
@@ -0,0 +1,592 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package planet is a client for the tcp server of the proxy, meant to be embedded in backend servers. A Client
+authenticates with the Tcp.Key of the proxy, sends transfers and broadcasts, waits for the proxy to respond to
+them and reconnects on its own whenever the connection is lost.
+*/
+package planet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sunproxy/sun/sun"
+	"go.uber.org/atomic"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed       = errors.New("planet client is closed")
+	ErrDisconnected = errors.New("connection to the proxy was lost before it responded")
+)
+
+/*
+ResponseError is returned when the proxy responded to a request with a status other than sun.PlanetStatusOK.
+*/
+type ResponseError struct {
+	Status  uint8
+	Message string
+}
+
+func (e ResponseError) Error() string {
+	return fmt.Sprintf("proxy responded with status %v: %v", e.Status, e.Message)
+}
+
+/*
+AuthError is returned when the proxy rejected the Key, Message is the reason the proxy gave.
+*/
+type AuthError struct {
+	Message string
+}
+
+func (e AuthError) Error() string {
+	return fmt.Sprintf("proxy rejected the key: %v", e.Message)
+}
+
+/*
+Config holds the settings used to connect to the proxy.
+*/
+type Config struct {
+	//Address is the address of the tcp server of the proxy.
+	Address sun.IpAddr
+	//Key is the Tcp.Key set in the config of the proxy.
+	Key string
+	//MinBackoff is the time waited before the first reconnect attempt, defaults to a second.
+	MinBackoff time.Duration
+	//MaxBackoff is the maximum time waited between reconnect attempts, defaults to a minute.
+	MaxBackoff time.Duration
+	//AuthTimeout is the time waited for the proxy to accept the Key, defaults to ten seconds.
+	AuthTimeout time.Duration
+	//ErrorLog is the logger connection errors are written to, defaults to one writing to stderr.
+	ErrorLog *log.Logger
+}
+
+/*
+Client is a connection to the proxy that is kept alive until it is closed.
+*/
+type Client struct {
+	cfg Config
+
+	mu      sync.Mutex
+	conn    *sun.Planet
+	ready   chan struct{}
+	pending map[uint32]chan packet.Packet
+	servers map[string]sun.Server
+
+	nextID *atomic.Uint32
+	events chan packet.Packet
+	close  chan struct{}
+	once   sync.Once
+}
+
+/*
+Dial connects to the proxy and authenticates. The first connection is made before Dial returns, after that the
+Client reconnects with backoff by itself whenever the connection is lost. An AuthError is returned if the proxy
+rejected the Key, the Client then stops reconnecting as it would be rejected again.
+*/
+func Dial(cfg Config) (*Client, error) {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.AuthTimeout <= 0 {
+		cfg.AuthTimeout = 10 * time.Second
+	}
+	if cfg.ErrorLog == nil {
+		cfg.ErrorLog = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	c := &Client{
+		cfg:     cfg,
+		ready:   make(chan struct{}),
+		pending: make(map[uint32]chan packet.Packet),
+		servers: make(map[string]sun.Server),
+		nextID:  atomic.NewUint32(0),
+		events:  make(chan packet.Packet, 64),
+		close:   make(chan struct{}),
+	}
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	go c.run(conn)
+	return c, nil
+}
+
+/*
+Events returns the channel every packet sent by the proxy that isn't a response to a request is sent on, such as
+a PlanetDisconnect. Events are dropped if the channel isn't drained.
+*/
+func (c *Client) Events() <-chan packet.Packet {
+	return c.events
+}
+
+/*
+Transfer asks the proxy to transfer the player with the uuid passed to the address passed and waits for the
+proxy to respond.
+*/
+func (c *Client) Transfer(ctx context.Context, user string, addr sun.IpAddr) error {
+	id := c.nextID.Inc()
+	resp, err := c.request(ctx, id, &sun.PlanetTransfer{RequestID: id, Address: addr.Address, Port: addr.Port, User: user})
+	if err != nil {
+		return err
+	}
+	if resp, ok := resp.(*sun.PlanetTransferResponse); ok && resp.Status != sun.PlanetStatusOK {
+		return ResponseError{Status: resp.Status, Message: resp.Error}
+	}
+	return nil
+}
+
+/*
+Broadcast sends a message to every player on the servers passed, or to every player on the proxy if no servers
+are passed, and waits for the proxy to respond.
+*/
+func (c *Client) Broadcast(ctx context.Context, message string, servers ...string) error {
+	return c.BroadcastText(ctx, sun.Text{Message: message, Servers: servers})
+}
+
+/*
+BroadcastText sends a Text to the players on its Servers, or to every player on the proxy if it has none, shown the
+way its Type says, and waits for the proxy to respond.
+*/
+func (c *Client) BroadcastText(ctx context.Context, text sun.Text) error {
+	id := c.nextID.Inc()
+	resp, err := c.request(ctx, id, &sun.PlanetText{RequestID: id, Text: text})
+	if err != nil {
+		return err
+	}
+	if resp, ok := resp.(*sun.PlanetTextResponse); ok && resp.Status != sun.PlanetStatusOK {
+		return ResponseError{Status: resp.Status, Message: resp.Error}
+	}
+	return nil
+}
+
+/*
+SendTitle shows a title, subtitle and action bar to the players in the Audience of the title.
+*/
+func (c *Client) SendTitle(ctx context.Context, title sun.Title) error {
+	return c.WritePacket(ctx, &sun.PlanetTitle{Title: title})
+}
+
+/*
+SendActionBar shows a message above the hotbar of the players in the audience passed.
+*/
+func (c *Client) SendActionBar(ctx context.Context, audience sun.Audience, message string) error {
+	return c.SendTitle(ctx, sun.Title{Audience: audience, ActionBar: message})
+}
+
+/*
+SetBossBar shows, updates or hides the boss bar of the proxy for the players in the Audience of the bar. The boss
+bar stays on screen when the players switch servers.
+*/
+func (c *Client) SetBossBar(ctx context.Context, bar sun.BossBar) error {
+	return c.WritePacket(ctx, &sun.PlanetBossBar{BossBar: bar})
+}
+
+/*
+PlaySound plays a sound to the players in the Audience of the sound where they stand.
+*/
+func (c *Client) PlaySound(ctx context.Context, sound sun.Sound) error {
+	return c.WritePacket(ctx, &sun.PlanetSound{Sound: sound})
+}
+
+/*
+RegisterServer adds a server to the Servers of the proxy, so it can be transferred to by name, and waits for the
+proxy to respond. The proxy removes it again when the connection is lost, so the Client registers it again every
+time it reconnects. A ResponseError is returned if the proxy rejected the server, for example because its name is
+taken by a server from the config of the proxy.
+*/
+func (c *Client) RegisterServer(ctx context.Context, server sun.Server) error {
+	name := strings.ToLower(server.Name)
+	c.mu.Lock()
+	c.servers[name] = server
+	c.mu.Unlock()
+
+	id := c.nextID.Inc()
+	resp, err := c.request(ctx, id, &sun.PlanetRegisterServer{RequestID: id, Server: server})
+	if err != nil {
+		return err
+	}
+	if resp, ok := resp.(*sun.PlanetRegisterServerResponse); ok && resp.Status != sun.PlanetStatusOK {
+		c.mu.Lock()
+		delete(c.servers, name)
+		c.mu.Unlock()
+		return ResponseError{Status: resp.Status, Message: resp.Error}
+	}
+	return nil
+}
+
+/*
+UnregisterServer removes a server registered with RegisterServer from the Servers of the proxy.
+*/
+func (c *Client) UnregisterServer(ctx context.Context, name string) error {
+	c.mu.Lock()
+	delete(c.servers, strings.ToLower(name))
+	c.mu.Unlock()
+	return c.WritePacket(ctx, &sun.PlanetUnregisterServer{Name: name})
+}
+
+/*
+SetPlayerCount tells the proxy how many players the servers of this planet have, the proxy uses it for the player
+count in the server list if its player count source is planets.
+*/
+func (c *Client) SetPlayerCount(ctx context.Context, count int) error {
+	return c.WritePacket(ctx, &sun.PlanetPlayerCount{Count: int32(count)})
+}
+
+/*
+SetMOTD shows the MOTD passed in the server list of the proxy instead of the configured ones, an empty MOTD
+restores them. The MOTD may use the same markup and placeholders as the configured ones.
+*/
+func (c *Client) SetMOTD(ctx context.Context, motd string) error {
+	return c.WritePacket(ctx, &sun.PlanetSetMOTD{MOTD: motd})
+}
+
+/*
+SetMaintenance enables or disables maintenance on the proxy, if kick is true the players online that aren't
+whitelisted are disconnected.
+*/
+func (c *Client) SetMaintenance(ctx context.Context, enabled, kick bool) error {
+	return c.WritePacket(ctx, &sun.PlanetMaintenance{Enabled: enabled, Kick: kick})
+}
+
+/*
+Ban bans the player with the XUID, name or IP passed on the whole network and disconnects them if they are
+online. A duration of 0 bans permanently.
+*/
+func (c *Client) Ban(ctx context.Context, kind, value, reason string, duration time.Duration) error {
+	return c.WritePacket(ctx, &sun.PlanetBan{Kind: kind, Value: value, Reason: reason, Duration: duration})
+}
+
+/*
+Unban lifts the ban of the kind and value passed.
+*/
+func (c *Client) Unban(ctx context.Context, kind, value string) error {
+	return c.WritePacket(ctx, &sun.PlanetUnban{Kind: kind, Value: value})
+}
+
+/*
+Mute stops the player with the XUID or name passed from chatting on the whole network. A duration of 0 mutes
+permanently.
+*/
+func (c *Client) Mute(ctx context.Context, player, reason string, duration time.Duration) error {
+	return c.WritePacket(ctx, &sun.PlanetMute{Player: player, Reason: reason, Duration: duration})
+}
+
+/*
+Unmute lifts the mute of the player with the XUID or name passed.
+*/
+func (c *Client) Unmute(ctx context.Context, player string) error {
+	return c.WritePacket(ctx, &sun.PlanetUnmute{Player: player})
+}
+
+/*
+Whitelist enables or disables the whitelist of the proxy, or adds or removes an XUID or name, depending on the
+action passed.
+*/
+func (c *Client) Whitelist(ctx context.Context, action uint8, entry string) error {
+	return c.WritePacket(ctx, &sun.PlanetWhitelist{Action: action, Entry: entry})
+}
+
+/*
+SetChannel adds a chat channel to the proxy or replaces the channel with the same name.
+*/
+func (c *Client) SetChannel(ctx context.Context, channel sun.Channel) error {
+	return c.WritePacket(ctx, &sun.PlanetChannel{Channel: channel})
+}
+
+/*
+RemoveChannel removes a chat channel from the proxy.
+*/
+func (c *Client) RemoveChannel(ctx context.Context, name string) error {
+	return c.WritePacket(ctx, &sun.PlanetChannel{Channel: sun.Channel{Name: name}, Remove: true})
+}
+
+/*
+SetChannelMember adds the player with the XUID or name passed to a channel or removes them from it, players need to
+be added to channels that are MembersOnly, such as parties. Names only match if the proxy has Xbox Live
+authentication enabled.
+*/
+func (c *Client) SetChannelMember(ctx context.Context, channel, player string, member bool) error {
+	return c.WritePacket(ctx, &sun.PlanetChannelMember{Channel: channel, Player: player, Member: member})
+}
+
+/*
+SetPermission grants or revokes a permission of the player with the XUID or name passed on the proxy, such as
+sun.command.server for the /server command.
+*/
+func (c *Client) SetPermission(ctx context.Context, player, permission string, granted bool) error {
+	return c.WritePacket(ctx, &sun.PlanetPermission{Player: player, Permission: permission, Granted: granted})
+}
+
+/*
+ServerStatus asks the proxy for the health of every backend it knows, as determined by its health checker.
+*/
+func (c *Client) ServerStatus(ctx context.Context) ([]sun.ServerStatusEntry, error) {
+	id := c.nextID.Inc()
+	resp, err := c.request(ctx, id, &sun.PlanetServerStatusRequest{RequestID: id})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*sun.PlanetServerStatusResponse).Servers, nil
+}
+
+/*
+WritePacket writes a packet to the proxy without waiting for a response, it blocks until the client is connected
+or the context is done.
+*/
+func (c *Client) WritePacket(ctx context.Context, pk packet.Packet) error {
+	conn, err := c.current(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.WritePacket(pk)
+}
+
+/*
+Close closes the connection to the proxy and stops reconnecting.
+*/
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		close(c.close)
+		c.mu.Lock()
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+		c.mu.Unlock()
+	})
+	return nil
+}
+
+func (c *Client) request(ctx context.Context, id uint32, pk packet.Packet) (packet.Packet, error) {
+	conn, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan packet.Packet, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := conn.WritePacket(pk); err != nil {
+		return nil, err
+	}
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrDisconnected
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.close:
+		return nil, ErrClosed
+	}
+}
+
+/*
+Returns the current connection, waiting for the client to reconnect if it is not connected.
+*/
+func (c *Client) current(ctx context.Context) (*sun.Planet, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+		if conn != nil {
+			return conn, nil
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.close:
+			return nil, ErrClosed
+		}
+	}
+}
+
+func (c *Client) connect() (*sun.Planet, error) {
+	conn, err := sun.NewPlanet(c.cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WritePacket(&sun.PlanetAuth{Key: c.cfg.Key}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := c.awaitAuth(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.close:
+		_ = conn.Close()
+		return nil, ErrClosed
+	default:
+	}
+	for _, server := range c.servers {
+		if err := conn.WritePacket(&sun.PlanetRegisterServer{RequestID: c.nextID.Inc(), Server: server}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	c.conn = conn
+	close(c.ready)
+	return conn, nil
+}
+
+/*
+Waits for the proxy to accept the key, it returns an AuthError if the proxy rejected it.
+*/
+func (c *Client) awaitAuth(conn *sun.Planet) error {
+	//the connection is closed to stop the read if the proxy doesn't respond in time
+	timer := time.AfterFunc(c.cfg.AuthTimeout, func() {
+		_ = conn.Close()
+	})
+	pk, err := conn.ReadPacket()
+	if !timer.Stop() {
+		return fmt.Errorf("proxy did not respond to the authentication within %v", c.cfg.AuthTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	switch pk := pk.(type) {
+	case *sun.PlanetAuthResponse:
+		return nil
+	case *sun.PlanetDisconnect:
+		return AuthError{Message: pk.Message}
+	}
+	return fmt.Errorf("unexpected packet %T while authenticating", pk)
+}
+
+func (c *Client) run(conn *sun.Planet) {
+	backoff := c.cfg.MinBackoff
+	for {
+		if conn != nil {
+			backoff = c.cfg.MinBackoff
+			c.read(conn)
+			c.disconnected()
+		}
+		select {
+		case <-c.close:
+			return
+		case <-time.After(backoff):
+		}
+		var err error
+		if conn, err = c.connect(); err != nil {
+			c.cfg.ErrorLog.Printf("error reconnecting to proxy at %v: %v\n", c.cfg.Address.ToString(), err)
+			if errors.As(err, &AuthError{}) {
+				_ = c.Close()
+				return
+			}
+			if backoff *= 2; backoff > c.cfg.MaxBackoff {
+				backoff = c.cfg.MaxBackoff
+			}
+		}
+	}
+}
+
+func (c *Client) read(conn *sun.Planet) {
+	for {
+		pk, err := conn.ReadPacket()
+		if errors.Is(err, sun.ErrUnknownPacket) {
+			//the proxy may be newer than the planet, so just skip what it doesn't understand
+			c.cfg.ErrorLog.Println(err)
+			continue
+		}
+		if err != nil {
+			select {
+			case <-c.close:
+			default:
+				c.cfg.ErrorLog.Printf("lost connection to proxy at %v: %v\n", c.cfg.Address.ToString(), err)
+			}
+			_ = conn.Close()
+			return
+		}
+		var id uint32
+		switch pk := pk.(type) {
+		case *sun.PlanetTransferResponse:
+			id = pk.RequestID
+		case *sun.PlanetTextResponse:
+			id = pk.RequestID
+		case *sun.PlanetServerStatusResponse:
+			id = pk.RequestID
+		case *sun.PlanetRegisterServerResponse:
+			id = pk.RequestID
+		default:
+			select {
+			case c.events <- pk:
+			default:
+			}
+			continue
+		}
+		c.mu.Lock()
+		if ch, ok := c.pending[id]; ok {
+			ch <- pk
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}
+}
+
+/*
+Clears the current connection and fails every request still waiting for a response.
+*/
+func (c *Client) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = nil
+	c.ready = make(chan struct{})
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
@@ -0,0 +1,164 @@
+package planet
+
+import (
+	"context"
+	"errors"
+	"github.com/sunproxy/sun/sun"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Starts a fake proxy accepting the key passed, handle is run for every planet that authenticated.
+*/
+func proxy(t *testing.T, key string, handle func(conn *sun.Planet)) sun.IpAddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn := sun.NewPlanetConn(c)
+			t.Cleanup(func() { _ = conn.Close() })
+			go func() {
+				pk, err := conn.ReadPacket()
+				if err != nil {
+					return
+				}
+				if pk, ok := pk.(*sun.PlanetAuth); !ok || pk.Key != key {
+					_ = conn.WritePacket(&sun.PlanetDisconnect{Message: "invalid key"})
+					_ = conn.Close()
+					return
+				}
+				_ = conn.WritePacket(&sun.PlanetAuthResponse{})
+				handle(conn)
+			}()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return sun.IpAddr{Address: addr.IP.String(), Port: uint16(addr.Port)}
+}
+
+func dial(t *testing.T, addr sun.IpAddr, key string) (*Client, error) {
+	c, err := Dial(Config{Address: addr, Key: key, MinBackoff: 10 * time.Millisecond, ErrorLog: log.New(ioutil.Discard, "", 0)})
+	if err == nil {
+		t.Cleanup(func() { _ = c.Close() })
+	}
+	return c, err
+}
+
+func TestDialRejected(t *testing.T) {
+	addr := proxy(t, "key", func(conn *sun.Planet) {})
+	if _, err := dial(t, addr, "wrong"); !errors.As(err, &AuthError{}) {
+		t.Fatalf("expected an AuthError, got %v", err)
+	}
+}
+
+func TestRequestCorrelation(t *testing.T) {
+	addr := proxy(t, "key", func(conn *sun.Planet) {
+		//respond to both transfers in the reverse order they were requested in
+		var transfers []*sun.PlanetTransfer
+		for len(transfers) < 2 {
+			pk, err := conn.ReadPacket()
+			if err != nil {
+				return
+			}
+			transfers = append(transfers, pk.(*sun.PlanetTransfer))
+		}
+		for i := len(transfers) - 1; i >= 0; i-- {
+			resp := &sun.PlanetTransferResponse{RequestID: transfers[i].RequestID, Status: sun.PlanetStatusOK}
+			if transfers[i].User == "missing" {
+				resp.Status, resp.Error = sun.PlanetStatusPlayerNotFound, "not found"
+			}
+			_ = conn.WritePacket(resp)
+		}
+	})
+	c, err := dial(t, addr, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Transfer(context.Background(), "missing", sun.IpAddr{Address: "hub"})
+	}()
+	//give the first request the time to be written first
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Transfer(context.Background(), "online", sun.IpAddr{Address: "hub"}); err != nil {
+		t.Fatalf("expected the second transfer to succeed, got %v", err)
+	}
+	var resp ResponseError
+	if err := <-errs; !errors.As(err, &resp) || resp.Status != sun.PlanetStatusPlayerNotFound {
+		t.Fatalf("expected the first transfer to fail with PlanetStatusPlayerNotFound, got %v", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	addr := proxy(t, "key", func(conn *sun.Planet) {
+		for {
+			if _, err := conn.ReadPacket(); err != nil {
+				return
+			}
+		}
+	})
+	c, err := dial(t, addr, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Transfer(ctx, "online", sun.IpAddr{Address: "hub"}); err != context.DeadlineExceeded {
+		t.Fatalf("expected the transfer to time out, got %v", err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	addr := proxy(t, "key", func(conn *sun.Planet) {
+		mu.Lock()
+		connections++
+		first := connections == 1
+		mu.Unlock()
+		if first {
+			//drop the first connection so the client has to reconnect
+			_ = conn.Close()
+			return
+		}
+		for {
+			pk, err := conn.ReadPacket()
+			if err != nil {
+				return
+			}
+			if pk, ok := pk.(*sun.PlanetTransfer); ok {
+				_ = conn.WritePacket(&sun.PlanetTransferResponse{RequestID: pk.RequestID})
+			}
+		}
+	})
+	c, err := dial(t, addr, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	//the request fails if it was written to the connection that was dropped, so it is retried
+	for c.Transfer(ctx, "online", sun.IpAddr{Address: "hub"}) != nil {
+		if ctx.Err() != nil {
+			t.Fatal("the client did not reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if connections < 2 {
+		t.Fatalf("expected the client to reconnect, got %v connections", connections)
+	}
+}
@@ -0,0 +1,480 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package sun
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"strings"
+	"time"
+)
+
+/*
+The unique and runtime ID of the entity the proxy shows its boss bar with, translatePacket leaves them alone.
+*/
+const (
+	ProxyBossEntityID        int64  = -0x53554e
+	ProxyBossEntityRuntimeID uint64 = 0x53554e53554e
+)
+
+/*
+The durations a title is shown with when none of them are set, the same as the client uses.
+*/
+const (
+	defaultTitleFadeIn  = 500 * time.Millisecond
+	defaultTitleStay    = 3500 * time.Millisecond
+	defaultTitleFadeOut = time.Second
+)
+
+/*
+Audience is who a Title, BossBar or Sound is shown to, the zero Audience is every player on the proxy.
+*/
+type Audience struct {
+	/*
+		Player is the name of a single player, the other fields are ignored if it is set
+	*/
+	Player string
+
+	/*
+		Group is a group of servers whose players are in the audience
+	*/
+	Group string
+
+	/*
+		Servers is an array of the IP addresses or names of servers whose players are in the audience
+	*/
+	Servers []string
+}
+
+func (a *Audience) marshal(w *protocol.Writer) {
+	w.String(&a.Player)
+	w.String(&a.Group)
+	l := uint32(len(a.Servers))
+	w.Varuint32(&l)
+	for _, server := range a.Servers {
+		w.String(&server)
+	}
+}
+
+func (a *Audience) unmarshal(r *protocol.Reader) {
+	r.String(&a.Player)
+	r.String(&a.Group)
+	var count uint32
+	r.Varuint32(&count)
+	r.LimitUint32(count, 256)
+	a.Servers = make([]string, count)
+	for i := range a.Servers {
+		r.String(&a.Servers[i])
+	}
+}
+
+/*
+Returns the players in the audience passed.
+*/
+func (s *Sun) audience(a Audience) []*Ray {
+	if a.Player != "" {
+		if ray, ok := s.Rays.ByName(a.Player); ok {
+			return []*Ray{ray}
+		}
+		return nil
+	}
+	if a.Group == "" && len(a.Servers) == 0 {
+		return s.Rays.All()
+	}
+	addrs := make(map[string]bool, len(a.Servers))
+	for _, server := range a.Servers {
+		//the server may be referred to by name
+		addrs[s.Servers.ResolveString(server)] = true
+	}
+	if a.Group != "" {
+		for _, server := range s.Servers.All() {
+			if strings.EqualFold(server.Group, a.Group) {
+				addrs[server.Address.ToString()] = true
+			}
+		}
+	}
+	var rays []*Ray
+	s.Rays.Range(func(ray *Ray) bool {
+		if addrs[ray.Remote().Addr().ToString()] {
+			rays = append(rays, ray)
+		}
+		return true
+	})
+	return rays
+}
+
+/*
+Title is a title, subtitle and action bar shown to the players in its Audience. Any of them may be left empty.
+*/
+type Title struct {
+	Audience
+
+	Title string
+
+	Subtitle string
+
+	ActionBar string
+
+	/*
+		The durations the title fades in, stays and fades out for, the client defaults are used if all are zero
+	*/
+	FadeIn, Stay, FadeOut time.Duration
+}
+
+func (pk *Title) ID() uint32 {
+	return IDRayTitle
+}
+
+func (pk *Title) Marshal(w *protocol.Writer) {
+	pk.Audience.marshal(w)
+	w.String(&pk.Title)
+	w.String(&pk.Subtitle)
+	w.String(&pk.ActionBar)
+	for _, d := range []time.Duration{pk.FadeIn, pk.Stay, pk.FadeOut} {
+		ticks := int32(d / (50 * time.Millisecond))
+		w.Varint32(&ticks)
+	}
+}
+
+func (pk *Title) Unmarshal(r *protocol.Reader) {
+	pk.Audience.unmarshal(r)
+	r.String(&pk.Title)
+	r.String(&pk.Subtitle)
+	r.String(&pk.ActionBar)
+	for _, d := range []*time.Duration{&pk.FadeIn, &pk.Stay, &pk.FadeOut} {
+		var ticks int32
+		r.Varint32(&ticks)
+		*d = time.Duration(ticks) * 50 * time.Millisecond
+	}
+}
+
+/*
+Returns the packets that show the title to a player.
+*/
+func (pk *Title) packets() []packet.Packet {
+	var pks []packet.Packet
+	if pk.Title != "" || pk.Subtitle != "" {
+		fadeIn, stay, fadeOut := pk.FadeIn, pk.Stay, pk.FadeOut
+		if fadeIn == 0 && stay == 0 && fadeOut == 0 {
+			fadeIn, stay, fadeOut = defaultTitleFadeIn, defaultTitleStay, defaultTitleFadeOut
+		}
+		pks = append(pks, &packet.SetTitle{
+			ActionType:      packet.TitleActionSetDurations,
+			FadeInDuration:  int32(fadeIn / (50 * time.Millisecond)),
+			RemainDuration:  int32(stay / (50 * time.Millisecond)),
+			FadeOutDuration: int32(fadeOut / (50 * time.Millisecond)),
+		})
+		if pk.Subtitle != "" {
+			pks = append(pks, &packet.SetTitle{ActionType: packet.TitleActionSetSubtitle, Text: pk.Subtitle})
+		}
+		//the subtitle is only shown along with a title
+		pks = append(pks, &packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: pk.Title})
+	}
+	if pk.ActionBar != "" {
+		pks = append(pks, &packet.SetTitle{ActionType: packet.TitleActionSetActionBar, Text: pk.ActionBar})
+	}
+	return pks
+}
+
+/*
+BossBar is the boss bar of the proxy shown to the players in its Audience, it stays on screen when they switch
+servers until it is hidden.
+*/
+type BossBar struct {
+	Audience
+
+	/*
+		Hide removes the boss bar instead of showing it
+	*/
+	Hide bool
+
+	Title string
+
+	/*
+		Health is how full the bar is, from 0 to 1
+	*/
+	Health float32
+
+	/*
+		Colour is the colour of the bar, it is ignored by most clients
+	*/
+	Colour uint32
+}
+
+func (pk *BossBar) ID() uint32 {
+	return IDRayBossBar
+}
+
+func (pk *BossBar) Marshal(w *protocol.Writer) {
+	pk.Audience.marshal(w)
+	w.Bool(&pk.Hide)
+	w.String(&pk.Title)
+	w.Float32(&pk.Health)
+	w.Varuint32(&pk.Colour)
+}
+
+func (pk *BossBar) Unmarshal(r *protocol.Reader) {
+	pk.Audience.unmarshal(r)
+	r.Bool(&pk.Hide)
+	r.String(&pk.Title)
+	r.Float32(&pk.Health)
+	r.Varuint32(&pk.Colour)
+}
+
+/*
+Sound is a sound played to the players in its Audience where they stand.
+*/
+type Sound struct {
+	Audience
+
+	/*
+		Name is the name of the sound, like "random.levelup"
+	*/
+	Name string
+
+	Volume float32
+
+	Pitch float32
+}
+
+func (pk *Sound) ID() uint32 {
+	return IDRaySound
+}
+
+func (pk *Sound) Marshal(w *protocol.Writer) {
+	pk.Audience.marshal(w)
+	w.String(&pk.Name)
+	w.Float32(&pk.Volume)
+	w.Float32(&pk.Pitch)
+}
+
+func (pk *Sound) Unmarshal(r *protocol.Reader) {
+	pk.Audience.unmarshal(r)
+	r.String(&pk.Name)
+	r.Float32(&pk.Volume)
+	r.Float32(&pk.Pitch)
+}
+
+/*
+SendTitle shows a title to a player.
+*/
+func (r *Ray) SendTitle(title *Title) error {
+	for _, pk := range title.packets() {
+		if err := r.conn.WritePacket(pk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+SetBossBar shows the boss bar of the proxy to a player, updates it if it is already shown or hides it if Hide is set.
+*/
+func (r *Ray) SetBossBar(bar *BossBar) error {
+	r.bossBarMu.Lock()
+	defer r.bossBarMu.Unlock()
+	if bar.Hide {
+		if r.bossBar == nil {
+			return nil
+		}
+		r.bossBar = nil
+		_ = r.conn.WritePacket(&packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID, EventType: packet.BossEventHide})
+		return r.conn.WritePacket(&packet.RemoveActor{EntityUniqueID: ProxyBossEntityID})
+	}
+	old := r.bossBar
+	b := *bar
+	r.bossBar = &b
+	if old == nil {
+		return r.showBossBar(&b)
+	}
+	if err := r.conn.WritePacket(&packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID, EventType: packet.BossEventTitle, BossBarTitle: b.Title}); err != nil {
+		return err
+	}
+	if old.Colour != b.Colour {
+		if err := r.conn.WritePacket(&packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID, EventType: packet.BossEventAppearanceProperties, Colour: b.Colour}); err != nil {
+			return err
+		}
+	}
+	return r.conn.WritePacket(&packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID, EventType: packet.BossEventHealthPercentage, HealthPercentage: b.Health})
+}
+
+/*
+Spawns the invisible entity the boss bar belongs to and shows the boss bar, it is also used to show the boss bar
+again after the entities of the player were cleared by a transfer.
+*/
+func (r *Ray) showBossBar(bar *BossBar) error {
+	err := r.conn.WritePacket(&packet.AddActor{
+		EntityUniqueID:  ProxyBossEntityID,
+		EntityRuntimeID: ProxyBossEntityRuntimeID,
+		EntityType:      "minecraft:slime",
+		Position:        r.Position(),
+		//the flags of the entity, it is invisible and immobile
+		EntityMetadata: map[uint32]interface{}{0: int64(1<<5 | 1<<16)},
+	})
+	if err != nil {
+		return err
+	}
+	return r.conn.WritePacket(&packet.BossEvent{
+		BossEntityUniqueID: ProxyBossEntityID,
+		EventType:          packet.BossEventShow,
+		BossBarTitle:       bar.Title,
+		HealthPercentage:   bar.Health,
+		Colour:             bar.Colour,
+	})
+}
+
+/*
+Shows the boss bar of the proxy again if the player had one, used once the player switched servers.
+*/
+func (r *Ray) restoreBossBar() {
+	r.bossBarMu.Lock()
+	defer r.bossBarMu.Unlock()
+	if r.bossBar != nil {
+		_ = r.showBossBar(r.bossBar)
+	}
+}
+
+/*
+PlaySound plays a sound to a player where they stand.
+*/
+func (r *Ray) PlaySound(sound *Sound) error {
+	return r.conn.WritePacket(&packet.PlaySound{SoundName: sound.Name, Position: r.Position(), Volume: sound.Volume, Pitch: sound.Pitch})
+}
+
+/*
+Position returns the last position the player reported.
+*/
+func (r *Ray) Position() mgl32.Vec3 {
+	r.positionMu.Lock()
+	defer r.positionMu.Unlock()
+	return r.position
+}
+
+func (r *Ray) setPosition(pos mgl32.Vec3) {
+	r.positionMu.Lock()
+	r.position = pos
+	r.positionMu.Unlock()
+}
+
+/*
+SendTitle shows a title to the players in its Audience, it returns the amount of players it was shown to.
+*/
+func (s *Sun) SendTitle(title *Title) int {
+	rays := s.audience(title.Audience)
+	for _, ray := range rays {
+		_ = ray.SendTitle(title)
+	}
+	return len(rays)
+}
+
+/*
+SendActionBar shows a message above the hotbar of the players in the audience passed.
+*/
+func (s *Sun) SendActionBar(audience Audience, message string) int {
+	return s.SendTitle(&Title{Audience: audience, ActionBar: message})
+}
+
+/*
+SetBossBar shows, updates or hides the boss bar of the proxy for the players in its Audience, it returns the amount
+of players it was set for.
+*/
+func (s *Sun) SetBossBar(bar *BossBar) int {
+	rays := s.audience(bar.Audience)
+	for _, ray := range rays {
+		_ = ray.SetBossBar(bar)
+	}
+	return len(rays)
+}
+
+/*
+PlaySound plays a sound to the players in its Audience, it returns the amount of players it was played to.
+*/
+func (s *Sun) PlaySound(sound *Sound) int {
+	rays := s.audience(sound.Audience)
+	for _, ray := range rays {
+		_ = ray.PlaySound(sound)
+	}
+	return len(rays)
+}
+
+/*
+Keeps track of the position of every player, so sounds and the boss bar entity are put where they stand.
+*/
+func (s *Sun) registerAnnouncements() {
+	s.Middleware.Serverbound(PriorityMonitor, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		switch pk := pk.(type) {
+		case *packet.MovePlayer:
+			ctx.Ray.setPosition(pk.Position)
+		case *packet.PlayerAuthInput:
+			ctx.Ray.setPosition(pk.Position)
+		}
+		return pk
+	})
+}
+
+/*
+PlanetTitle is sent by a planet to show a Title.
+*/
+type PlanetTitle struct {
+	Title
+}
+
+func (pk *PlanetTitle) ID() uint32 {
+	return IDPlanetTitle
+}
+
+/*
+PlanetBossBar is sent by a planet to show, update or hide the boss bar of the proxy.
+*/
+type PlanetBossBar struct {
+	BossBar
+}
+
+func (pk *PlanetBossBar) ID() uint32 {
+	return IDPlanetBossBar
+}
+
+/*
+PlanetSound is sent by a planet to play a Sound.
+*/
+type PlanetSound struct {
+	Sound
+}
+
+func (pk *PlanetSound) ID() uint32 {
+	return IDPlanetSound
+}
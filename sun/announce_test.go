@@ -0,0 +1,54 @@
+package sun
+
+import (
+	"bytes"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTitle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	title := &Title{Audience: Audience{Group: "lobby", Servers: []string{"hub"}}, Title: "Hello", Subtitle: "World", Stay: time.Second}
+	title.Marshal(protocol.NewWriter(buf, 0))
+	pk := &Title{}
+	pk.Unmarshal(protocol.NewReader(buf, 0))
+	if !reflect.DeepEqual(pk, title) {
+		t.Fatalf("expected %#v, got %#v", title, pk)
+	}
+
+	pks := pk.packets()
+	if len(pks) != 3 {
+		t.Fatalf("expected the durations, subtitle and title, got %v packets", len(pks))
+	}
+	if d := pks[0].(*packet.SetTitle); d.ActionType != packet.TitleActionSetDurations || d.RemainDuration != 20 {
+		t.Fatalf("unexpected durations %#v", d)
+	}
+	if pks := (&Title{ActionBar: "Hi"}).packets(); len(pks) != 1 || pks[0].(*packet.SetTitle).ActionType != packet.TitleActionSetActionBar {
+		t.Fatalf("expected only the action bar, got %#v", pks)
+	}
+}
+
+func TestBossEntityNotTranslated(t *testing.T) {
+	r := &Ray{Translations: &TranslatorMappings{OriginalEntityUniqueID: ProxyBossEntityID, CurrentEntityUniqueID: 1}}
+	pk := &packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID}
+	r.translatePacket(pk)
+	if pk.BossEntityUniqueID != ProxyBossEntityID {
+		t.Fatalf("the boss entity was translated to %v", pk.BossEntityUniqueID)
+	}
+}
+
+func TestParseAudience(t *testing.T) {
+	for in, expected := range map[string]Audience{
+		"all":         {},
+		"server:hub":  {Servers: []string{"hub"}},
+		"Group:lobby": {Group: "lobby"},
+		"Steve":       {Player: "Steve"},
+	} {
+		if a := parseAudience(in); !reflect.DeepEqual(a, expected) {
+			t.Fatalf("expected %v to be %#v, got %#v", in, expected, a)
+		}
+	}
+}
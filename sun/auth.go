@@ -18,3 +18,14 @@ func (p *PlanetAuth) Unmarshal(r *protocol.Reader) {
 	r.String(&p.Key)
 }
 
+// PlanetAuthResponse is sent to a planet once its key was accepted, a planet with a wrong key gets a
+// PlanetDisconnect instead
+type PlanetAuthResponse struct{}
+
+func (p *PlanetAuthResponse) ID() uint32 {
+	return IDPlanetAuthResponse
+}
+
+func (p *PlanetAuthResponse) Marshal(*protocol.Writer) {}
+
+func (p *PlanetAuthResponse) Unmarshal(*protocol.Reader) {}
@@ -0,0 +1,652 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+The kinds of values a Ban can match.
+*/
+const (
+	BanXUID = "xuid"
+	BanName = "name"
+	BanIP   = "ip"
+)
+
+/*
+Ban keeps a player with the XUID, name or IP in Value from joining the proxy.
+*/
+type Ban struct {
+	//Kind is BanXUID, BanName or BanIP.
+	Kind   string
+	Value  string
+	Reason string
+	//Source is who issued the ban, such as the console or the address of a planet.
+	Source  string
+	Created time.Time
+	//Expires is the zero time for permanent bans.
+	Expires time.Time
+}
+
+/*
+Returns true if the ban expired at the time passed.
+*/
+func (b Ban) Expired(at time.Time) bool {
+	return !b.Expires.IsZero() && !at.Before(b.Expires)
+}
+
+/*
+Returns true if the ban matches the player with the identity and IP passed. Name bans only match if names is true.
+*/
+func (b Ban) Matches(identity login.IdentityData, ip string, names bool) bool {
+	switch b.Kind {
+	case BanXUID:
+		return identity.XUID != "" && identity.XUID == b.Value
+	case BanName:
+		return names && strings.EqualFold(identity.DisplayName, b.Value)
+	case BanIP:
+		return ip == b.Value
+	}
+	return false
+}
+
+/*
+Returns the disconnect message shown to the banned player.
+*/
+func (b Ban) Message() string {
+	msg := "You are banned from this network"
+	if b.Reason != "" {
+		msg += ": " + b.Reason
+	}
+	if !b.Expires.IsZero() {
+		msg += "\nUntil " + b.Expires.Format("2006-01-02 15:04 MST")
+	}
+	return msg
+}
+
+/*
+BanList is a file backed store of the bans and the whitelist of the proxy, every change is written to the file
+immediately.
+*/
+type BanList struct {
+	//MatchNames allows name bans, mutes and whitelist entries to match, see matchesPlayer.
+	MatchNames bool
+
+	path string
+
+	mu   sync.RWMutex
+	data banData
+}
+
+type banData struct {
+	Bans      []Ban
+	Mutes     []Mute
+	Whitelist struct {
+		Enabled bool
+		Entries []string
+	}
+}
+
+/*
+Mute keeps the player with the XUID or name in Player from chatting.
+*/
+type Mute struct {
+	Player  string
+	Reason  string
+	Source  string
+	Created time.Time
+	//Expires is the zero time for permanent mutes.
+	Expires time.Time
+}
+
+/*
+Returns true if the mute expired at the time passed.
+*/
+func (m Mute) Expired(at time.Time) bool {
+	return !m.Expires.IsZero() && !at.Before(m.Expires)
+}
+
+/*
+Returns the message shown to the muted player when they try to chat.
+*/
+func (m Mute) Message() string {
+	msg := "You are muted"
+	if m.Reason != "" {
+		msg += ": " + m.Reason
+	}
+	if !m.Expires.IsZero() {
+		msg += " until " + m.Expires.Format("2006-01-02 15:04 MST")
+	}
+	return msg
+}
+
+/*
+Loads the BanList stored at path, the file is created on the first change if it doesn't exist.
+*/
+func LoadBanList(path string) (*BanList, error) {
+	b := &BanList{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.data); err != nil {
+		return nil, fmt.Errorf("error decoding %v: %v", path, err)
+	}
+	return b, nil
+}
+
+/*
+Reads the list from its file again, discarding what is in memory.
+*/
+func (b *BanList) Reload() error {
+	loaded, err := LoadBanList(b.path)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.data = loaded.data
+	b.mu.Unlock()
+	return nil
+}
+
+/*
+Writes the list to its file, the lock must be held.
+*/
+func (b *BanList) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.data, "", " ")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+/*
+Adds a ban, replacing any ban of the same kind and value.
+*/
+func (b *BanList) Add(ban Ban) error {
+	ban.Kind = strings.ToLower(ban.Kind)
+	switch ban.Kind {
+	case BanXUID, BanName, BanIP:
+	default:
+		return fmt.Errorf("unknown ban kind %v", ban.Kind)
+	}
+	if ban.Created.IsZero() {
+		ban.Created = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(ban.Kind, ban.Value)
+	b.data.Bans = append(b.data.Bans, ban)
+	return b.save()
+}
+
+/*
+Removes the ban of the kind and value passed, it returns false if there was none.
+*/
+func (b *BanList) Remove(kind, value string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.remove(strings.ToLower(kind), value) {
+		return false, nil
+	}
+	return true, b.save()
+}
+
+func (b *BanList) remove(kind, value string) bool {
+	for i, ban := range b.data.Bans {
+		if ban.Kind == kind && strings.EqualFold(ban.Value, value) {
+			b.data.Bans = append(b.data.Bans[:i], b.data.Bans[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Returns every ban that didn't expire yet.
+*/
+func (b *BanList) All() []Ban {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	now := time.Now()
+	var bans []Ban
+	for _, ban := range b.data.Bans {
+		if !ban.Expired(now) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans
+}
+
+/*
+Returns the ban matching the player with the identity and IP passed, expired bans are skipped.
+*/
+func (b *BanList) Check(identity login.IdentityData, ip string) (Ban, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	now := time.Now()
+	for _, ban := range b.data.Bans {
+		if !ban.Expired(now) && ban.Matches(identity, ip, b.MatchNames) {
+			return ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+/*
+Removes the expired bans and mutes from the list, the file is only written if any expired.
+*/
+func (b *BanList) Prune() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	bans := b.data.Bans[:0]
+	for _, ban := range b.data.Bans {
+		if !ban.Expired(now) {
+			bans = append(bans, ban)
+		}
+	}
+	mutes := b.data.Mutes[:0]
+	for _, mute := range b.data.Mutes {
+		if !mute.Expired(now) {
+			mutes = append(mutes, mute)
+		}
+	}
+	pruned := len(bans) != len(b.data.Bans) || len(mutes) != len(b.data.Mutes)
+	b.data.Bans, b.data.Mutes = bans, mutes
+	if !pruned {
+		return nil
+	}
+	return b.save()
+}
+
+/*
+Prunes the list every interval until close is closed, so expired entries don't pile up in the file.
+*/
+func (b *BanList) runPrune(interval time.Duration, close <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := b.Prune(); err != nil {
+			log.Println("error pruning the ban list:", err)
+		}
+		select {
+		case <-close:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Mutes a player, replacing any mute of the same player.
+*/
+func (b *BanList) Mute(mute Mute) error {
+	if mute.Created.IsZero() {
+		mute.Created = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unmute(mute.Player)
+	b.data.Mutes = append(b.data.Mutes, mute)
+	return b.save()
+}
+
+/*
+Lifts the mute of the player with the XUID or name passed, it returns false if they weren't muted.
+*/
+func (b *BanList) Unmute(player string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.unmute(player) {
+		return false, nil
+	}
+	return true, b.save()
+}
+
+func (b *BanList) unmute(player string) bool {
+	for i, mute := range b.data.Mutes {
+		if strings.EqualFold(mute.Player, player) {
+			b.data.Mutes = append(b.data.Mutes[:i], b.data.Mutes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Returns every mute that didn't expire yet.
+*/
+func (b *BanList) Mutes() []Mute {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	now := time.Now()
+	var mutes []Mute
+	for _, mute := range b.data.Mutes {
+		if !mute.Expired(now) {
+			mutes = append(mutes, mute)
+		}
+	}
+	return mutes
+}
+
+/*
+Returns the mute of the player with the identity passed, if they are muted.
+*/
+func (b *BanList) Muted(identity login.IdentityData) (Mute, bool) {
+	for _, mute := range b.Mutes() {
+		if matchesPlayer(mute.Player, identity, b.MatchNames) {
+			return mute, true
+		}
+	}
+	return Mute{}, false
+}
+
+/*
+Enables or disables the whitelist.
+*/
+func (b *BanList) SetWhitelist(enabled bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data.Whitelist.Enabled = enabled
+	return b.save()
+}
+
+/*
+Returns true if the whitelist is enabled.
+*/
+func (b *BanList) WhitelistEnabled() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.data.Whitelist.Enabled
+}
+
+/*
+Adds an XUID or name to the whitelist.
+*/
+func (b *BanList) AddWhitelist(entry string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.data.Whitelist.Entries {
+		if strings.EqualFold(e, entry) {
+			return nil
+		}
+	}
+	b.data.Whitelist.Entries = append(b.data.Whitelist.Entries, entry)
+	return b.save()
+}
+
+/*
+Removes an XUID or name from the whitelist.
+*/
+func (b *BanList) RemoveWhitelist(entry string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, e := range b.data.Whitelist.Entries {
+		if strings.EqualFold(e, entry) {
+			b.data.Whitelist.Entries = append(b.data.Whitelist.Entries[:i], b.data.Whitelist.Entries[i+1:]...)
+			return b.save()
+		}
+	}
+	return nil
+}
+
+/*
+Returns true if the player with the identity passed may join, which is always the case if the whitelist is
+disabled.
+*/
+func (b *BanList) Whitelisted(identity login.IdentityData) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.data.Whitelist.Enabled {
+		return true
+	}
+	for _, e := range b.data.Whitelist.Entries {
+		if matchesPlayer(e, identity, b.MatchNames) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Returns true if the XUID or name passed belongs to the player with the identity passed. Names are only compared if
+names is true: without Xbox Live authentication anyone can join with any name.
+*/
+func matchesPlayer(entry string, identity login.IdentityData, names bool) bool {
+	return (identity.XUID != "" && entry == identity.XUID) || (names && strings.EqualFold(entry, identity.DisplayName))
+}
+
+/*
+Returns the IP of the address passed without its port.
+*/
+func hostIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+/*
+Bans a player network-wide and disconnects every player online the ban matches.
+*/
+func (s *Sun) BanPlayer(ban Ban) error {
+	ban.Kind = strings.ToLower(ban.Kind)
+	if err := s.Bans.Add(ban); err != nil {
+		return err
+	}
+	for _, ray := range s.Rays.All() {
+		if ban.Matches(ray.IdentityData(), hostIP(ray.conn.RemoteAddr()), s.Bans.MatchNames) {
+			s.DisconnectRay(ray, ban.Message())
+		}
+	}
+	return nil
+}
+
+/*
+Enables or disables the whitelist, when it is enabled every player online that isn't whitelisted is disconnected.
+*/
+func (s *Sun) SetWhitelist(enabled bool) error {
+	if err := s.Bans.SetWhitelist(enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	for _, ray := range s.Rays.All() {
+		if !s.Bans.Whitelisted(ray.IdentityData()) {
+			s.DisconnectRay(ray, s.WhitelistMessage)
+		}
+	}
+	return nil
+}
+
+/*
+PlanetBan is sent by a planet to ban a player network-wide, a Duration of 0 bans permanently.
+*/
+type PlanetBan struct {
+	Kind     string
+	Value    string
+	Reason   string
+	Duration time.Duration
+}
+
+func (pk *PlanetBan) ID() uint32 {
+	return IDPlanetBan
+}
+
+func (pk *PlanetBan) Marshal(w *protocol.Writer) {
+	w.String(&pk.Kind)
+	w.String(&pk.Value)
+	w.String(&pk.Reason)
+	seconds := int64(pk.Duration / time.Second)
+	w.Varint64(&seconds)
+}
+
+func (pk *PlanetBan) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Kind)
+	r.String(&pk.Value)
+	r.String(&pk.Reason)
+	var seconds int64
+	r.Varint64(&seconds)
+	pk.Duration = time.Duration(seconds) * time.Second
+}
+
+/*
+PlanetUnban is sent by a planet to lift a ban.
+*/
+type PlanetUnban struct {
+	Kind  string
+	Value string
+}
+
+func (pk *PlanetUnban) ID() uint32 {
+	return IDPlanetUnban
+}
+
+func (pk *PlanetUnban) Marshal(w *protocol.Writer) {
+	w.String(&pk.Kind)
+	w.String(&pk.Value)
+}
+
+func (pk *PlanetUnban) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Kind)
+	r.String(&pk.Value)
+}
+
+/*
+PlanetMute is sent by a planet to mute the player with the XUID or name in Player on the whole network, a Duration
+of 0 mutes permanently.
+*/
+type PlanetMute struct {
+	Player   string
+	Reason   string
+	Duration time.Duration
+}
+
+func (pk *PlanetMute) ID() uint32 {
+	return IDPlanetMute
+}
+
+func (pk *PlanetMute) Marshal(w *protocol.Writer) {
+	w.String(&pk.Player)
+	w.String(&pk.Reason)
+	seconds := int64(pk.Duration / time.Second)
+	w.Varint64(&seconds)
+}
+
+func (pk *PlanetMute) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Player)
+	r.String(&pk.Reason)
+	var seconds int64
+	r.Varint64(&seconds)
+	pk.Duration = time.Duration(seconds) * time.Second
+}
+
+/*
+PlanetUnmute is sent by a planet to lift the mute of a player.
+*/
+type PlanetUnmute struct {
+	Player string
+}
+
+func (pk *PlanetUnmute) ID() uint32 {
+	return IDPlanetUnmute
+}
+
+func (pk *PlanetUnmute) Marshal(w *protocol.Writer) {
+	w.String(&pk.Player)
+}
+
+func (pk *PlanetUnmute) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Player)
+}
+
+/*
+The actions of a PlanetWhitelist.
+*/
+const (
+	WhitelistDisable uint8 = iota
+	WhitelistEnable
+	WhitelistAdd
+	WhitelistRemove
+)
+
+/*
+PlanetWhitelist is sent by a planet to enable or disable the whitelist, or to add or remove the Entry.
+*/
+type PlanetWhitelist struct {
+	Action uint8
+	Entry  string
+}
+
+func (pk *PlanetWhitelist) ID() uint32 {
+	return IDPlanetWhitelist
+}
+
+func (pk *PlanetWhitelist) Marshal(w *protocol.Writer) {
+	w.Uint8(&pk.Action)
+	w.String(&pk.Entry)
+}
+
+func (pk *PlanetWhitelist) Unmarshal(r *protocol.Reader) {
+	r.Uint8(&pk.Action)
+	r.String(&pk.Entry)
+}
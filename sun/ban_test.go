@@ -0,0 +1,85 @@
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	b, err := LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steve := login.IdentityData{XUID: "123", DisplayName: "Steve"}
+	if err := b.Add(Ban{Kind: BanName, Value: "steve", Reason: "griefing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(Ban{Kind: BanIP, Value: "10.0.0.1", Expires: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Check(steve, "10.0.0.2"); ok {
+		t.Fatal("expected the name ban not to match without MatchNames")
+	}
+	b.MatchNames = true
+	if ban, ok := b.Check(steve, "10.0.0.2"); !ok || ban.Reason != "griefing" {
+		t.Fatalf("expected steve to be banned by name, got %v %v", ban, ok)
+	}
+	if _, ok := b.Check(login.IdentityData{DisplayName: "Alex"}, "10.0.0.1"); ok {
+		t.Fatal("expected the expired ip ban not to match")
+	}
+
+	//the ban has to survive a reload
+	b, err = LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.All()) != 1 {
+		t.Fatalf("expected 1 ban after reloading, got %v", b.All())
+	}
+	if removed, err := b.Remove(BanName, "STEVE"); err != nil || !removed {
+		t.Fatalf("expected the ban to be removed, got %v %v", removed, err)
+	}
+	if _, ok := b.Check(steve, ""); ok {
+		t.Fatal("expected steve to be unbanned")
+	}
+
+	if err := b.SetWhitelist(true); err != nil {
+		t.Fatal(err)
+	}
+	if b.Whitelisted(steve) {
+		t.Fatal("expected steve not to be whitelisted")
+	}
+	_ = b.AddWhitelist("123")
+	if !b.Whitelisted(steve) {
+		t.Fatal("expected steve to be whitelisted by xuid")
+	}
+}
+
+func TestBanListExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	b, _ := LoadBanList(path)
+	_ = b.Add(Ban{Kind: BanIP, Value: "10.0.0.1", Expires: time.Now().Add(-time.Minute)})
+	_ = b.Add(Ban{Kind: "XUID", Value: "123"})
+	_ = b.Mute(Mute{Player: "Steve", Expires: time.Now().Add(-time.Minute)})
+	if ban, ok := b.Check(login.IdentityData{}, "10.0.0.1"); ok {
+		t.Fatalf("expected the expired ban not to match, got %#v", ban)
+	}
+	if _, ok := b.Check(login.IdentityData{XUID: "123"}, "10.0.0.1"); !ok {
+		t.Fatal("expected the xuid ban to match")
+	}
+	if err := b.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "10.0.0.1") || strings.Contains(string(data), "Steve") {
+		t.Fatal("expected the expired ban and mute to be removed from the file")
+	}
+}
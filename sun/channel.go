@@ -0,0 +1,464 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Channel is a chat channel relayed by the proxy to players on every server.
+*/
+type Channel struct {
+	Name string
+	//Format is coloured with text.Colourf, {player}, {server} and {message} are replaced after colouring.
+	Format string
+	//Permission is required to read and write in the channel, everyone may if it is empty.
+	Permission string
+	//MembersOnly channels, such as parties, only reach the players added to them by a planet.
+	MembersOnly bool
+	//Servers limits the channel to the players on these servers, it reaches every server if it is empty.
+	Servers []string
+}
+
+/*
+Returns the message a player sent in the channel as it is shown to the players reading it.
+*/
+func (ch Channel) format(player, server, message string) string {
+	return strings.NewReplacer("{player}", player, "{server}", server, "{message}", message).Replace(text.Colourf("%s", ch.Format))
+}
+
+/*
+Channels holds the chat channels, their members and the channel every player is talking in. Members are the lower
+case XUIDs or names set by planets, so membership applies before the player joins. The channel a player talks in and
+who they reply to are kept by the identity of the player, as names can be spoofed without Xbox Live authentication.
+*/
+type Channels struct {
+	mu       sync.RWMutex
+	channels map[string]Channel
+	members  map[string]map[string]bool
+	current  map[string]string
+	replies  map[string]string
+}
+
+/*
+Returns new Channels with the channels passed.
+*/
+func NewChannels(channels []Channel) *Channels {
+	c := &Channels{
+		channels: make(map[string]Channel),
+		members:  make(map[string]map[string]bool),
+		current:  make(map[string]string),
+		replies:  make(map[string]string),
+	}
+	for _, ch := range channels {
+		c.Add(ch)
+	}
+	return c
+}
+
+/*
+Adds a channel, replacing the channel with the same name.
+*/
+func (c *Channels) Add(ch Channel) {
+	c.mu.Lock()
+	c.channels[strings.ToLower(ch.Name)] = ch
+	c.mu.Unlock()
+}
+
+/*
+Removes a channel and its members, players talking in it go back to the chat of their server.
+*/
+func (c *Channels) Remove(name string) {
+	name = strings.ToLower(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.channels, name)
+	delete(c.members, name)
+	for player, ch := range c.current {
+		if ch == name {
+			delete(c.current, player)
+		}
+	}
+}
+
+/*
+Returns the channel with the name passed.
+*/
+func (c *Channels) Get(name string) (Channel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ch, ok := c.channels[strings.ToLower(name)]
+	return ch, ok
+}
+
+/*
+Returns every channel sorted by name.
+*/
+func (c *Channels) All() []Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	channels := make([]Channel, 0, len(c.channels))
+	for _, ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+	return channels
+}
+
+/*
+Adds the player with the XUID or name passed to a channel or removes them from it. A player that is removed while
+talking in the channel is moved back to the chat of their server the next time they talk.
+*/
+func (c *Channels) SetMember(channel, player string, member bool) {
+	channel, player = strings.ToLower(channel), strings.ToLower(player)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !member {
+		delete(c.members[channel], player)
+		return
+	}
+	if c.members[channel] == nil {
+		c.members[channel] = make(map[string]bool)
+	}
+	c.members[channel][player] = true
+}
+
+/*
+Returns true if the player with the identity passed was added to the channel by their XUID, or by their name if names
+is true.
+*/
+func (c *Channels) IsMember(channel string, identity login.IdentityData, names bool) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	members := c.members[strings.ToLower(channel)]
+	return (identity.XUID != "" && members[identity.XUID]) || (names && members[strings.ToLower(identity.DisplayName)])
+}
+
+/*
+Returns the channel the player with the identity passed is talking in, or an empty string if they talk in the chat of
+their server.
+*/
+func (c *Channels) Current(player string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current[player]
+}
+
+/*
+Makes the player with the identity passed talk in the channel passed, an empty channel is the chat of their server.
+*/
+func (c *Channels) SetCurrent(player, channel string) {
+	channel = strings.ToLower(channel)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if channel == "" {
+		delete(c.current, player)
+		return
+	}
+	c.current[player] = channel
+}
+
+/*
+Returns the identity of the player who last sent a private message to or received one from the player with the
+identity passed.
+*/
+func (c *Channels) Reply(player string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	identity, ok := c.replies[player]
+	return identity, ok
+}
+
+func (c *Channels) setReply(from, to string) {
+	c.mu.Lock()
+	c.replies[from] = to
+	c.replies[to] = from
+	c.mu.Unlock()
+}
+
+/*
+Forgets the channel the player with the identity passed talks in and who they talked to, memberships are kept.
+*/
+func (c *Channels) Forget(player string) {
+	c.mu.Lock()
+	delete(c.current, player)
+	delete(c.replies, player)
+	c.mu.Unlock()
+}
+
+/*
+Returns true if the player may read and write in the channel.
+*/
+func (s *Sun) CanUseChannel(ray *Ray, ch Channel) bool {
+	if !s.Permissions.Has(ray.IdentityData(), ch.Permission) {
+		return false
+	}
+	return !ch.MembersOnly || s.Channels.IsMember(ch.Name, ray.IdentityData(), s.Permissions.MatchNames)
+}
+
+/*
+Sends a message of the player passed to everyone that may read the channel, on whichever server they are. Muted
+players can't send messages and the ChatFilter is run on the message.
+*/
+func (s *Sun) SendToChannel(sender *Ray, ch Channel, message string) error {
+	if !s.CanUseChannel(sender, ch) {
+		return fmt.Errorf("you can't talk in %v", ch.Name)
+	}
+	message, err := s.checkChat(sender, message)
+	if err != nil {
+		return err
+	}
+	s.sendToChannel(sender, ch, message)
+	return nil
+}
+
+/*
+Sends a message to the channel without checking it, for chat that went through the chat middleware already.
+*/
+func (s *Sun) sendToChannel(sender *Ray, ch Channel, message string) {
+	msg := ch.format(sender.IdentityData().DisplayName, s.Servers.Name(*sender.Remote().Addr()), message)
+	s.sendMessageWhere(msg, ch.Servers, func(ray *Ray) bool {
+		return s.CanUseChannel(ray, ch)
+	})
+}
+
+/*
+Sends a private message from one player to another, wherever they are. Muted players can't send messages and the
+ChatFilter is run on the message.
+*/
+func (s *Sun) PrivateMessage(from, to *Ray, message string) error {
+	message, err := s.checkChat(from, message)
+	if err != nil {
+		return err
+	}
+	fromName, toName := from.IdentityData().DisplayName, to.IdentityData().DisplayName
+	if err := to.SendMessage(text.Colourf("<grey>[%v -> me]</grey> ", fromName) + message); err != nil {
+		return fmt.Errorf("%v can't receive messages", toName)
+	}
+	s.Channels.setReply(from.IdentityData().Identity, to.IdentityData().Identity)
+	return from.SendMessage(text.Colourf("<grey>[me -> %v]</grey> ", toName) + message)
+}
+
+/*
+Registers /channel, /msg and /r, and the middleware that moves the chat of players talking in a channel to it.
+*/
+func (s *Sun) registerChannels() {
+	s.Middleware.Serverbound(PriorityNormal, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		msg, ok := pk.(*packet.Text)
+		if !ok || msg.TextType != packet.TextTypeChat {
+			return pk
+		}
+		name := s.Channels.Current(ctx.Ray.IdentityData().Identity)
+		if name == "" {
+			return pk
+		}
+		ch, ok := s.Channels.Get(name)
+		if !ok || !s.CanUseChannel(ctx.Ray, ch) {
+			//the channel is gone or the player lost access, so talk on the server again
+			s.Channels.SetCurrent(ctx.Ray.IdentityData().Identity, "")
+			_ = ctx.Ray.SendMessage(text.Colourf("<red>You can't talk in %v anymore, you are back in the server chat</red>", name))
+			return nil
+		}
+		//the chat middleware checked the message already
+		s.sendToChannel(ctx.Ray, ch, msg.Message)
+		return nil
+	})
+	s.Events.OnDisconnect(PriorityMonitor, func(e *DisconnectEvent) {
+		s.Channels.Forget(e.Ray.IdentityData().Identity)
+	})
+
+	messageParam := protocol.CommandParameter{Name: "message", Type: protocol.CommandArgValid | protocol.CommandArgTypeMessage}
+	for _, cmd := range []PlayerCommand{
+		{
+			Name:        "channel",
+			Aliases:     []string{"ch"},
+			Description: "Switches the chat channel you talk in or sends one message to it",
+			Parameters: []protocol.CommandParameter{
+				{Name: "channel", Type: protocol.CommandArgValid | protocol.CommandArgTypeString, Optional: true},
+				{Name: messageParam.Name, Type: messageParam.Type, Optional: true},
+			},
+			Run: func(ray *Ray, args []string) error {
+				player := ray.IdentityData().Identity
+				if len(args) == 0 {
+					names := []string{"server"}
+					for _, ch := range s.Channels.All() {
+						if s.CanUseChannel(ray, ch) {
+							names = append(names, ch.Name)
+						}
+					}
+					current := s.Channels.Current(player)
+					if current == "" {
+						current = "server"
+					}
+					return ray.SendMessage(text.Colourf("<yellow>You talk in %v, channels: %v</yellow>", current, strings.Join(names, ", ")))
+				}
+				if strings.EqualFold(args[0], "server") {
+					s.Channels.SetCurrent(player, "")
+					return ray.SendMessage(text.Colourf("<yellow>You now talk in the server chat</yellow>"))
+				}
+				ch, ok := s.Channels.Get(args[0])
+				if !ok || !s.CanUseChannel(ray, ch) {
+					return fmt.Errorf("there is no channel %v", args[0])
+				}
+				if len(args) > 1 {
+					return s.SendToChannel(ray, ch, strings.Join(args[1:], " "))
+				}
+				s.Channels.SetCurrent(player, ch.Name)
+				return ray.SendMessage(text.Colourf("<yellow>You now talk in %v</yellow>", ch.Name))
+			},
+		},
+		{
+			Name:        "msg",
+			Aliases:     []string{"tell", "w"},
+			Description: "Sends a private message to a player on any server",
+			Parameters: []protocol.CommandParameter{
+				{Name: "player", Type: protocol.CommandArgValid | protocol.CommandArgTypeTarget},
+				messageParam,
+			},
+			Run: func(ray *Ray, args []string) error {
+				if len(args) < 2 {
+					return errors.New("usage: /msg <player> <message>")
+				}
+				to, ok := s.Rays.ByName(args[0])
+				if !ok {
+					return fmt.Errorf("%v is not online", args[0])
+				}
+				return s.PrivateMessage(ray, to, strings.Join(args[1:], " "))
+			},
+		},
+		{
+			Name:        "r",
+			Aliases:     []string{"reply"},
+			Description: "Replies to the last private message",
+			Parameters:  []protocol.CommandParameter{messageParam},
+			Run: func(ray *Ray, args []string) error {
+				if len(args) == 0 {
+					return errors.New("usage: /r <message>")
+				}
+				identity, ok := s.Channels.Reply(ray.IdentityData().Identity)
+				if !ok {
+					return errors.New("you have no one to reply to")
+				}
+				to, ok := s.Rays.ByIdentity(identity)
+				if !ok {
+					return errors.New("the player you talked to is not online")
+				}
+				return s.PrivateMessage(ray, to, strings.Join(args, " "))
+			},
+		},
+	} {
+		cmd.Permission = "sun.command." + cmd.Name
+		_ = s.Commands.Register(cmd)
+	}
+}
+
+/*
+PlanetChannel is sent by a planet to add or replace a chat channel, or to remove it if Remove is true.
+*/
+type PlanetChannel struct {
+	Channel Channel
+	Remove  bool
+}
+
+func (pk *PlanetChannel) ID() uint32 {
+	return IDPlanetChannel
+}
+
+func (pk *PlanetChannel) Marshal(w *protocol.Writer) {
+	w.String(&pk.Channel.Name)
+	w.String(&pk.Channel.Format)
+	w.String(&pk.Channel.Permission)
+	w.Bool(&pk.Channel.MembersOnly)
+	l := uint32(len(pk.Channel.Servers))
+	w.Varuint32(&l)
+	for _, server := range pk.Channel.Servers {
+		w.String(&server)
+	}
+	w.Bool(&pk.Remove)
+}
+
+func (pk *PlanetChannel) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Channel.Name)
+	r.String(&pk.Channel.Format)
+	r.String(&pk.Channel.Permission)
+	r.Bool(&pk.Channel.MembersOnly)
+	var count uint32
+	r.Varuint32(&count)
+	r.LimitUint32(count, 256)
+	pk.Channel.Servers = make([]string, count)
+	for i := range pk.Channel.Servers {
+		r.String(&pk.Channel.Servers[i])
+	}
+	r.Bool(&pk.Remove)
+}
+
+/*
+PlanetChannelMember is sent by a planet to add the player with the XUID or name in Player to a channel, or to remove
+them. Names only match players if the proxy authenticates them with Xbox Live.
+*/
+type PlanetChannelMember struct {
+	Channel string
+	Player  string
+	Member  bool
+}
+
+func (pk *PlanetChannelMember) ID() uint32 {
+	return IDPlanetChannelMember
+}
+
+func (pk *PlanetChannelMember) Marshal(w *protocol.Writer) {
+	w.String(&pk.Channel)
+	w.String(&pk.Player)
+	w.Bool(&pk.Member)
+}
+
+func (pk *PlanetChannelMember) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Channel)
+	r.String(&pk.Player)
+	r.Bool(&pk.Member)
+}
@@ -0,0 +1,75 @@
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChannels(t *testing.T) {
+	c := NewChannels([]Channel{{Name: "global", Format: "<aqua>[G]</aqua> {player}: {message}"}})
+	ch, ok := c.Get("GLOBAL")
+	if !ok {
+		t.Fatal("expected channels to be looked up case insensitively")
+	}
+	if msg := ch.format("Steve", "hub", "<red>hi</red>"); !strings.HasSuffix(msg, "Steve: <red>hi</red>") || !strings.Contains(msg, "§b[G]") {
+		t.Fatalf("expected the format to be coloured but not the message, got %q", msg)
+	}
+
+	steve := login.IdentityData{Identity: "steve-id", XUID: "123", DisplayName: "Steve"}
+	c.Add(Channel{Name: "party-1", MembersOnly: true})
+	c.SetMember("party-1", "Steve", true)
+	if c.IsMember("party-1", steve, false) || !c.IsMember("party-1", steve, true) {
+		t.Fatal("expected steve to only be in the party by name if names are trusted")
+	}
+	c.SetMember("party-1", "123", true)
+	c.SetCurrent(steve.Identity, "party-1")
+	if !c.IsMember("PARTY-1", steve, false) || c.Current(steve.Identity) != "party-1" {
+		t.Fatal("expected steve to be in the party by xuid")
+	}
+	if c.Current("Steve") != "" {
+		t.Fatal("expected the current channel to be kept by identity, not name")
+	}
+	c.SetMember("party-1", "123", false)
+	if c.IsMember("party-1", steve, false) {
+		t.Fatal("expected steve to have left the party")
+	}
+
+	c.SetCurrent("alex-id", "global")
+	c.Remove("global")
+	if c.Current("alex-id") != "" {
+		t.Fatal("expected alex to be back in the server chat after the channel was removed")
+	}
+	c.setReply(steve.Identity, "alex-id")
+	if identity, ok := c.Reply("alex-id"); !ok || identity != steve.Identity {
+		t.Fatalf("expected alex to reply to steve, got %v", identity)
+	}
+}
+
+func TestChannelMuted(t *testing.T) {
+	bans, err := LoadBanList(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Sun{Bans: bans, Permissions: NewPermissions(nil, nil), Channels: NewChannels([]Channel{{Name: "global"}})}
+	s.ChatFilter, _ = NewChatFilter([]string{"badword"}, nil, FilterBlock)
+	steve, alex := testRay(1, IpAddr{}), testRay(2, IpAddr{})
+	ch, _ := s.Channels.Get("global")
+
+	if err := s.SendToChannel(steve, ch, "a badword"); err == nil {
+		t.Fatal("expected the chat filter to block the channel message")
+	}
+	if err := s.PrivateMessage(steve, alex, "a badword"); err == nil {
+		t.Fatal("expected the chat filter to block the private message")
+	}
+	if err := s.Bans.Mute(Mute{Player: steve.IdentityData().XUID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendToChannel(steve, ch, "hello"); err == nil {
+		t.Fatal("expected a muted player not to be able to talk in a channel")
+	}
+	if err := s.PrivateMessage(steve, alex, "hello"); err == nil {
+		t.Fatal("expected a muted player not to be able to send a private message")
+	}
+}
@@ -0,0 +1,285 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+/*
+FilterAction is what the ChatFilter does with a message.
+*/
+type FilterAction uint8
+
+const (
+	//FilterAllow passes the message on unchanged.
+	FilterAllow FilterAction = iota
+	//FilterBlock drops the message and tells the player why.
+	FilterBlock
+	//FilterCensor passes the message on with the offending parts replaced.
+	FilterCensor
+	//FilterWarn passes the message on unchanged and warns the player.
+	FilterWarn
+)
+
+/*
+Parses block, censor or warn into a FilterAction.
+*/
+func ParseFilterAction(action string) (FilterAction, error) {
+	switch strings.ToLower(action) {
+	case "block":
+		return FilterBlock, nil
+	case "censor":
+		return FilterCensor, nil
+	case "warn":
+		return FilterWarn, nil
+	}
+	return FilterAllow, fmt.Errorf("unknown chat filter action %v", action)
+}
+
+/*
+ChatFilter checks the chat messages of players against word lists and regexes, limits capital letters and
+stops spam and repeated messages. Spam and repeated messages are always blocked, every other rule uses the Action.
+*/
+type ChatFilter struct {
+	Action FilterAction
+	//MaxCaps is the highest percentage of capital letters a message may have, 0 disables the limit.
+	MaxCaps int
+	//MinCapsLength is the length a message needs before MaxCaps applies.
+	MinCapsLength int
+	//SpamLimit is how many messages a player may send every SpamInterval, 0 disables the limit.
+	SpamLimit    int
+	SpamInterval time.Duration
+	//RepeatLimit is how many times in a row a player may send the same message, 0 disables the limit.
+	RepeatLimit int
+
+	patterns []*regexp.Regexp
+
+	mu      sync.Mutex
+	history map[string]*chatHistory
+}
+
+type chatHistory struct {
+	sent    []time.Time
+	last    string
+	repeats int
+}
+
+/*
+Returns a new ChatFilter matching the words passed as whole words and the patterns passed as regexes, both ignoring
+case.
+*/
+func NewChatFilter(words, patterns []string, action FilterAction) (*ChatFilter, error) {
+	f := &ChatFilter{Action: action, history: make(map[string]*chatHistory)}
+	for _, word := range words {
+		f.patterns = append(f.patterns, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(word)+`\b`))
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat filter pattern %v: %v", pattern, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+/*
+Checks a message of the player with the identifier passed. It returns the action taken, the message to pass on if
+it isn't blocked and the reason if it isn't allowed.
+*/
+func (f *ChatFilter) Check(player, message string) (FilterAction, string, string) {
+	if reason := f.record(player, message, time.Now()); reason != "" {
+		return FilterBlock, message, reason
+	}
+	action, reason := FilterAllow, ""
+	for _, re := range f.patterns {
+		if re.MatchString(message) {
+			action, reason = f.Action, "Your message contains blocked words"
+			if f.Action == FilterCensor {
+				message = re.ReplaceAllStringFunc(message, func(s string) string {
+					return strings.Repeat("*", len([]rune(s)))
+				})
+			}
+		}
+	}
+	if f.tooManyCaps(message) {
+		if action == FilterAllow {
+			action, reason = f.Action, "Your message has too many capital letters"
+		}
+		if f.Action == FilterCensor {
+			message = strings.ToLower(message)
+		}
+	}
+	return action, message, reason
+}
+
+/*
+Returns true if more than MaxCaps percent of the letters in the message are capitals.
+*/
+func (f *ChatFilter) tooManyCaps(message string) bool {
+	if f.MaxCaps <= 0 || len([]rune(message)) < f.MinCapsLength {
+		return false
+	}
+	letters, caps := 0, 0
+	for _, r := range message {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				caps++
+			}
+		}
+	}
+	return letters > 0 && caps*100 > letters*f.MaxCaps
+}
+
+/*
+Records a message of the player, returning why it is blocked if it is spam or repeated.
+*/
+func (f *ChatFilter) record(player, message string, now time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.history[player]
+	if !ok {
+		h = &chatHistory{}
+		f.history[player] = h
+	}
+	if f.SpamLimit > 0 {
+		recent := h.sent[:0]
+		for _, t := range h.sent {
+			if now.Sub(t) < f.SpamInterval {
+				recent = append(recent, t)
+			}
+		}
+		h.sent = recent
+		if len(h.sent) >= f.SpamLimit {
+			return "You are sending messages too fast"
+		}
+		h.sent = append(h.sent, now)
+	}
+	if f.RepeatLimit > 0 {
+		if strings.EqualFold(h.last, message) {
+			h.repeats++
+		} else {
+			h.last, h.repeats = message, 1
+		}
+		if h.repeats > f.RepeatLimit {
+			return "You can't send the same message again"
+		}
+	}
+	return ""
+}
+
+/*
+Forgets the messages of a player.
+*/
+func (f *ChatFilter) Forget(player string) {
+	f.mu.Lock()
+	delete(f.history, player)
+	f.mu.Unlock()
+}
+
+/*
+Registers the middleware that stops muted players from chatting and runs the ChatFilter on chat messages.
+*/
+func (s *Sun) registerChatFilter() {
+	s.Middleware.Serverbound(PriorityLow, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		msg, ok := pk.(*packet.Text)
+		if !ok || msg.TextType != packet.TextTypeChat {
+			return pk
+		}
+		message, err := s.checkChat(ctx.Ray, msg.Message)
+		if err != nil {
+			_ = ctx.Ray.SendMessage(text.Colourf("<red>%v</red>", err))
+			return nil
+		}
+		msg.Message = message
+		return msg
+	})
+	if s.ChatFilter != nil {
+		s.Events.OnDisconnect(PriorityMonitor, func(e *DisconnectEvent) {
+			s.ChatFilter.Forget(e.Ray.IdentityData().Identity)
+		})
+	}
+}
+
+/*
+Returns the message a player wants to send as the ChatFilter lets it through, or an error if the player is muted or
+the message is blocked. Every message the proxy sends on behalf of a player has to go through it.
+*/
+func (s *Sun) checkChat(ray *Ray, message string) (string, error) {
+	if mute, ok := s.Bans.Muted(ray.IdentityData()); ok {
+		return "", errors.New(mute.Message())
+	}
+	if s.ChatFilter == nil {
+		return message, nil
+	}
+	action, message, reason := s.ChatFilter.Check(ray.IdentityData().Identity, message)
+	switch action {
+	case FilterBlock:
+		return "", errors.New(reason)
+	case FilterWarn:
+		_ = ray.SendMessage(text.Colourf("<yellow>%v</yellow>", reason))
+	}
+	return message, nil
+}
+
+/*
+Mutes a player network-wide and tells them if they are online.
+*/
+func (s *Sun) MutePlayer(mute Mute) error {
+	if err := s.Bans.Mute(mute); err != nil {
+		return err
+	}
+	ray, ok := s.Rays.ByXUID(mute.Player)
+	if !ok && s.Bans.MatchNames {
+		//the mute only applies by name when names can be trusted
+		ray, ok = s.Rays.ByName(mute.Player)
+	}
+	if ok {
+		_ = ray.SendMessage(text.Colourf("<red>%v</red>", mute.Message()))
+	}
+	return nil
+}
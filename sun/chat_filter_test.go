@@ -0,0 +1,47 @@
+package sun
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChatFilter(t *testing.T) {
+	f, err := NewChatFilter([]string{"darn"}, []string{`fr[e3]{2}\s*stuff`}, FilterCensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.MaxCaps, f.MinCapsLength = 50, 6
+	if action, msg, _ := f.Check("a", "Darn it, free stuff here"); action != FilterCensor || msg != "**** it, ********** here" {
+		t.Fatalf("expected the message to be censored, got %v %q", action, msg)
+	}
+	if action, msg, _ := f.Check("a", "darning is fine"); action != FilterAllow || msg != "darning is fine" {
+		t.Fatalf("expected only whole words to match, got %v %q", action, msg)
+	}
+	if action, msg, _ := f.Check("a", "STOP SHOUTING"); action != FilterCensor || msg != "stop shouting" {
+		t.Fatalf("expected the caps to be lowered, got %v %q", action, msg)
+	}
+
+	f.Action = FilterBlock
+	if action, _, reason := f.Check("a", "darn"); action != FilterBlock || reason == "" {
+		t.Fatalf("expected the message to be blocked, got %v", action)
+	}
+}
+
+func TestChatFilterSpam(t *testing.T) {
+	f, _ := NewChatFilter(nil, nil, FilterBlock)
+	f.SpamLimit, f.SpamInterval, f.RepeatLimit = 3, time.Minute, 2
+	for i, msg := range []string{"hi", "HI"} {
+		if action, _, _ := f.Check("a", msg); action != FilterAllow {
+			t.Fatalf("expected message %v to be allowed", i)
+		}
+	}
+	if action, _, _ := f.Check("a", "hi"); action != FilterBlock {
+		t.Fatal("expected the third repeat to be blocked")
+	}
+	if action, _, _ := f.Check("a", "something else"); action != FilterBlock {
+		t.Fatal("expected the fourth message in a minute to be blocked as spam")
+	}
+	if action, _, _ := f.Check("b", "hi"); action != FilterAllow {
+		t.Fatal("expected other players not to be affected")
+	}
+}
@@ -38,6 +38,8 @@ package sun
 
 import (
 	"bytes"
+	crand "crypto/rand"
+	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
 	"encoding/xml"
@@ -59,12 +61,193 @@ type Config struct {
 
 	Hub IpAddr
 
+	/*
+		Players are moved to the first of these servers that is up when their server goes down and the Hub is down too
+	*/
+	Fallbacks []IpAddr
+
+	/*
+		The servers that can be referred to by name in transfers and messages
+	*/
+	Servers []Server
+
+	HubBalancer struct {
+		/*
+			The Servers in this group are used as hubs, the Hub is used if there are none
+		*/
+		Group string
+
+		/*
+			How players are spread over the hubs: round-robin, least-players, random or hash
+		*/
+		Strategy string
+
+		/*
+			The seconds a hub that failed to dial is skipped for
+		*/
+		Cooldown int
+	}
+
+	HealthCheck struct {
+		/*
+			Specifies if the backends should be pinged in the background
+		*/
+		Enabled bool
+
+		/*
+			The seconds between pings and the seconds a ping may take
+		*/
+		Interval int
+		Timeout  int
+
+		/*
+			The successful or failed pings in a row it takes to mark a server up or down
+		*/
+		UpThreshold   int
+		DownThreshold int
+	}
+
+	MOTD struct {
+		/*
+			The server names shown in the server list in turn, Status.ServerName is used if there are none.
+			They may use colour tags and {online}, {max}, {servers_up} and {version}
+		*/
+		Messages []string
+
+		/*
+			The seconds each of the Messages is shown for
+		*/
+		Interval int
+	}
+
+	Maintenance struct {
+		/*
+			Specifies if only the Whitelist may join, the players online are kept when it is enabled in the config
+		*/
+		Enabled bool
+
+		/*
+			The disconnect message and the MOTD shown while maintenance is enabled
+		*/
+		Message string
+		MOTD    string
+
+		/*
+			The XUIDs and names that may join during maintenance
+		*/
+		Whitelist []string
+	}
+
+	Bans struct {
+		/*
+			The file the bans and the whitelist are stored in
+		*/
+		File string
+
+		/*
+			The disconnect message players that aren't whitelisted get while the whitelist is enabled
+		*/
+		WhitelistMessage string
+	}
+
+	Commands struct {
+		/*
+			The permissions everyone has, the proxy commands need sun.command.<name>. Moving to a server by address with
+			/server needs sun.command.server.address
+		*/
+		DefaultPermissions []string
+
+		/*
+			The permissions of single players by XUID or name
+		*/
+		Permissions []PlayerPermissions
+	}
+
+	ChatFilter struct {
+		/*
+			Specifies if the chat of players is filtered, muted players can't chat either way
+		*/
+		Enabled bool
+
+		/*
+			The words and regexes that aren't allowed in chat, and what happens to messages with them: block, censor or warn
+		*/
+		Words    []string
+		Patterns []string
+		Action   string
+
+		/*
+			The highest percentage of capital letters in messages of at least MinCapsLength characters, -1 disables it
+		*/
+		MaxCaps       int
+		MinCapsLength int
+
+		/*
+			The messages a player may send every SpamInterval seconds and the times in a row they may send the same message, -1 disables them
+		*/
+		SpamLimit    int
+		SpamInterval int
+		RepeatLimit  int
+	}
+
+	Chat struct {
+		/*
+			The chat channels relayed to every server, players need sun.command.channel to switch to them
+		*/
+		Channels []Channel
+	}
+
+	PlayerCount struct {
+		/*
+			Where the player count in the server list comes from: proxy, backends (needs HealthCheck), peers or planets
+		*/
+		Source string
+
+		/*
+			The planet (Tcp) addresses of the other proxies behind the same address, their players are added when the
+			Source is peers. The other proxies need Tcp enabled with the same Key
+		*/
+		Peers []IpAddr
+
+		/*
+			How the max players in the server list are worked out: fixed (Status.MaxPlayers), sum of the backends or online+1
+		*/
+		MaxPolicy string
+	}
+
 	Proxy struct {
 		Port uint16
 
+		/*
+			Without it names can't be trusted, so bans, mutes, whitelists and permissions only match players by XUID
+		*/
 		XboxAuthentication bool
 
 		IpForwarding bool
+
+		/*
+			Used to sign the forwarded addresses so backends can verify they were set by the proxy
+		*/
+		ForwardingKey string
+
+		/*
+			How players are moved between servers: seamless picks a dimension the player passes through from the one
+			they are in and applies the game rules, time, spawn and game mode of the new server, legacy always passes
+			through the nether
+		*/
+		TransferMode string
+	}
+
+	Shutdown struct {
+		/*
+			Players are transferred to this server when the proxy shuts down, they are disconnected if it is empty
+		*/
+		Fallback IpAddr
+
+		/*
+			The message players are disconnected with when the proxy shuts down
+		*/
+		Message string
 	}
 
 	Tcp struct {
@@ -164,12 +347,88 @@ func defaultConfig(config Config) Config {
 		config.Hub.Port = 19133
 		config.Hub.Address = "0.0.0.0"
 	}
+	if len(config.Servers) == 0 {
+		config.Servers = []Server{{Name: "hub", Address: config.Hub}}
+	}
+	if config.HubBalancer.Strategy == "" {
+		config.HubBalancer.Strategy = "round-robin"
+	}
+	if config.HubBalancer.Cooldown == 0 {
+		config.HubBalancer.Cooldown = 30
+	}
+	if config.HealthCheck.Interval == 0 {
+		config.HealthCheck.Interval = 5
+	}
+	if config.HealthCheck.Timeout == 0 {
+		config.HealthCheck.Timeout = 2
+	}
+	if config.HealthCheck.UpThreshold == 0 {
+		config.HealthCheck.UpThreshold = 2
+	}
+	if config.HealthCheck.DownThreshold == 0 {
+		config.HealthCheck.DownThreshold = 3
+	}
+	if config.MOTD.Interval == 0 {
+		config.MOTD.Interval = 10
+	}
+	if config.Proxy.TransferMode == "" {
+		config.Proxy.TransferMode = TransferSeamless
+	}
+	if config.PlayerCount.Source == "" {
+		config.PlayerCount.Source = "proxy"
+	}
+	if config.PlayerCount.MaxPolicy == "" {
+		config.PlayerCount.MaxPolicy = "fixed"
+	}
 	emptyStatus := minecraft.ServerStatus{}
 	if config.Status == emptyStatus {
 		config.Status.MaxPlayers = 50
 		config.Status.PlayerCount = 0
 		config.Status.ServerName = text.Colourf("<yellow>Sun Proxy</yellow>")
 	}
+	if config.Maintenance.Message == "" {
+		config.Maintenance.Message = text.Colourf("<red>The network is under maintenance, try again later!</red>")
+	}
+	if config.Maintenance.MOTD == "" {
+		config.Maintenance.MOTD = "<red>Maintenance</red>"
+	}
+	if config.Bans.File == "" {
+		config.Bans.File = "bans.json"
+	}
+	if config.Bans.WhitelistMessage == "" {
+		config.Bans.WhitelistMessage = text.Colourf("<red>You are not whitelisted on this network!</red>")
+	}
+	if config.Commands.DefaultPermissions == nil {
+		config.Commands.DefaultPermissions = []string{"sun.command.server", "sun.command.hub", "sun.command.glist", "sun.command.find",
+			"sun.command.channel", "sun.command.msg", "sun.command.r"}
+	}
+	if config.ChatFilter.Action == "" {
+		config.ChatFilter.Action = "censor"
+	}
+	if config.ChatFilter.MaxCaps == 0 {
+		config.ChatFilter.MaxCaps = 70
+	}
+	if config.ChatFilter.MinCapsLength == 0 {
+		config.ChatFilter.MinCapsLength = 6
+	}
+	if config.ChatFilter.SpamLimit == 0 {
+		config.ChatFilter.SpamLimit = 5
+	}
+	if config.ChatFilter.SpamInterval == 0 {
+		config.ChatFilter.SpamInterval = 5
+	}
+	if config.ChatFilter.RepeatLimit == 0 {
+		config.ChatFilter.RepeatLimit = 3
+	}
+	if config.Chat.Channels == nil {
+		config.Chat.Channels = []Channel{
+			{Name: "global", Format: "<aqua>[Global]</aqua> <grey>[{server}]</grey> {player}: {message}"},
+			{Name: "staff", Format: "<gold>[Staff]</gold> <grey>[{server}]</grey> {player}: {message}", Permission: "sun.channel.staff"},
+		}
+	}
+	if config.Shutdown.Message == "" {
+		config.Shutdown.Message = text.Colourf("<red>Sun Proxy is shutting down!</red>")
+	}
 	//Generate a random Key if its empty
 	if config.Tcp.Key == "" {
 		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -180,9 +439,23 @@ func defaultConfig(config Config) Config {
 		}
 		config.Tcp.Key = GenKey()
 	}
+	if config.Proxy.ForwardingKey == "" {
+		config.Proxy.ForwardingKey = genSecret()
+	}
 	return config
 }
 
+/*
+Returns a random key for signing, unlike GenKey it can't be guessed from the time the config was generated at.
+*/
+func genSecret() string {
+	b := make([]byte, 32)
+	if _, err := crand.Read(b); err != nil {
+		return GenKey()
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 func GenKey() string {
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	Chars := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -2,6 +2,8 @@ package sun
 
 import (
 	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 )
@@ -16,3 +18,43 @@ func BenchmarkGenKey(b *testing.B) {
 		}
 	}
 }
+
+func TestApplyConfig(t *testing.T) {
+	var old Config
+	old.Servers = []Server{
+		{Name: "hub", Address: IpAddr{Address: "127.0.0.1", Port: 19133}},
+		{Name: "old", Address: IpAddr{Address: "127.0.0.1", Port: 19134}},
+	}
+	old.Maintenance.Whitelist = []string{"123", "Steve"}
+	s := &Sun{
+		Servers:     NewServerRegistry(old.Servers),
+		MOTD:        NewMOTD(nil, time.Second),
+		Maintenance: NewMaintenance(false, "", "", old.Maintenance.Whitelist),
+		config:      old,
+	}
+	s.Servers.Add(Server{Name: "planet", Address: IpAddr{Address: "127.0.0.1", Port: 19135}})
+	s.Maintenance.AddWhitelist("456")
+
+	var config Config
+	config.Servers = []Server{
+		{Name: "hub", Address: IpAddr{Address: "127.0.0.1", Port: 19136}},
+		{Name: "new", Address: IpAddr{Address: "127.0.0.1", Port: 19137}},
+	}
+	config.Maintenance.Whitelist = []string{"STEVE", "789"}
+	s.applyConfig(config)
+
+	var names []string
+	for _, server := range s.Servers.All() {
+		names = append(names, server.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"hub", "new", "planet"}) {
+		t.Fatalf("expected hub, new and planet, got %v", names)
+	}
+	if hub, _ := s.Servers.ByName("hub"); hub.Address.Port != 19136 {
+		t.Fatal("expected the address of hub to be updated")
+	}
+	if whitelist := s.Maintenance.Whitelist(); !reflect.DeepEqual(whitelist, []string{"456", "789", "steve"}) {
+		t.Fatalf("expected 456, 789 and steve to be whitelisted, got %v", whitelist)
+	}
+}
@@ -0,0 +1,328 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ErrUsage is returned by a Command when it is run with the wrong arguments, the console prints its Usage.
+*/
+var ErrUsage = errors.New("wrong usage")
+
+/*
+Command is a command that can be run from the console of the proxy.
+*/
+type Command struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	//Run runs the command with the arguments after its name and writes its output to out.
+	Run func(args []string, out io.Writer) error
+	//Complete returns the candidates for the last of the arguments passed, it may be nil.
+	Complete func(args []string) []string
+}
+
+/*
+Console runs the commands an operator types on stdin. Embedders can add their own commands with Register.
+*/
+type Console struct {
+	sun *Sun
+	//Stop is called by the stop command, it shuts the proxy down by default.
+	Stop func()
+
+	mu       sync.RWMutex
+	commands map[string]*Command
+}
+
+/*
+Returns a new Console for the proxy passed with the built in commands registered.
+*/
+func NewConsole(s *Sun) *Console {
+	c := &Console{sun: s, commands: make(map[string]*Command)}
+	c.Stop = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Println(err)
+		}
+	}
+	c.registerDefaults()
+	return c
+}
+
+/*
+Registers a command, it fails if the name or one of the aliases is taken.
+*/
+func (c *Console) Register(cmd Command) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, ok := c.commands[strings.ToLower(name)]; ok {
+			return fmt.Errorf("command %v is already registered", name)
+		}
+	}
+	for _, name := range names {
+		c.commands[strings.ToLower(name)] = &cmd
+	}
+	return nil
+}
+
+/*
+Returns the command with the name or alias passed.
+*/
+func (c *Console) Command(name string) (*Command, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cmd, ok := c.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+/*
+Returns every registered command sorted by name.
+*/
+func (c *Console) Commands() []*Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var cmds []*Command
+	for name, cmd := range c.commands {
+		if strings.EqualFold(name, cmd.Name) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+/*
+Runs the command line passed and writes the output to out.
+*/
+func (c *Console) Execute(line string, out io.Writer) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return
+	}
+	cmd, ok := c.Command(args[0])
+	if !ok {
+		_, _ = fmt.Fprintf(out, "Unknown command %v, type help for a list of commands\n", args[0])
+		return
+	}
+	if err := cmd.Run(args[1:], out); err != nil {
+		if errors.Is(err, ErrUsage) {
+			_, _ = fmt.Fprintf(out, "Usage: %v %v\n", cmd.Name, cmd.Usage)
+			return
+		}
+		_, _ = fmt.Fprintln(out, err)
+	}
+}
+
+/*
+Returns the candidates to complete the last word of the line passed with.
+*/
+func (c *Console) Complete(line string) []string {
+	args := strings.Fields(line)
+	//an empty last word is being typed if the line ends with a space
+	if len(args) == 0 || strings.HasSuffix(line, " ") {
+		args = append(args, "")
+	}
+	var candidates []string
+	if len(args) == 1 {
+		for _, cmd := range c.Commands() {
+			candidates = append(candidates, cmd.Name)
+		}
+	} else if cmd, ok := c.Command(args[0]); ok && cmd.Complete != nil {
+		candidates = cmd.Complete(args[1:])
+	}
+	last := strings.ToLower(args[len(args)-1])
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), last) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+/*
+Reads command lines from in and runs them until in is closed or the proxy shuts down.
+*/
+func (c *Console) Run(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	lines := c.readLines(func() (string, error) {
+		if !scanner.Scan() {
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	})
+	for {
+		select {
+		case <-c.sun.close:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			c.Execute(line, out)
+		}
+	}
+}
+
+/*
+Sends every line read with read to the channel returned, which is closed once read fails. The routine reading stops
+once the proxy shuts down, at the latest when the line it is reading is done.
+*/
+func (c *Console) readLines(read func() (string, error)) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			line, err := read()
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- line:
+			case <-c.sun.close:
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+/*
+Runs the console on stdin in a new routine and returns a channel that is closed once it stopped. If stdin is a
+terminal it is put in raw mode so commands can be completed with tab, the log is written through the terminal so it
+doesn't mess up the line being typed. Raw mode turns Ctrl-C into the end of the input, so Stop is called then, and the
+terminal is only restored once the channel is closed.
+*/
+func (c *Console) RunStdin() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.runStdin()
+	}()
+	return done
+}
+
+func (c *Console) runStdin() {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		c.Run(os.Stdin, os.Stdout)
+		return
+	}
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		c.Run(os.Stdin, os.Stdout)
+		return
+	}
+	defer func() {
+		_ = terminal.Restore(fd, state)
+	}()
+	term := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "> ")
+	term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return c.completeLine(term, line, pos)
+	}
+	log.SetOutput(term)
+	defer log.SetOutput(os.Stderr)
+
+	lines := c.readLines(term.ReadLine)
+	for {
+		select {
+		case <-c.sun.close:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				//Ctrl-C or Ctrl-D, which can't raise SIGINT in raw mode
+				if !c.sun.closing() {
+					c.Stop()
+				}
+				return
+			}
+			c.Execute(line, term)
+		}
+	}
+}
+
+/*
+Completes the line typed on a terminal, it completes the common prefix of the candidates and prints them if
+there is more than one.
+*/
+func (c *Console) completeLine(term *terminal.Terminal, line string, pos int) (string, int, bool) {
+	typed, rest := line[:pos], line[pos:]
+	matches := c.Complete(typed)
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	prefix := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(strings.ToLower(match), strings.ToLower(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) > 1 {
+		_, _ = fmt.Fprintln(term, strings.Join(matches, "  "))
+	} else {
+		prefix += " "
+	}
+	start := strings.LastIndex(typed, " ") + 1
+	typed = typed[:start] + prefix
+	return typed + rest, len(typed), true
+}
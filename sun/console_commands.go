@@ -0,0 +1,571 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (c *Console) registerDefaults() {
+	s := c.sun
+	for _, cmd := range []Command{
+		{
+			Name:        "help",
+			Aliases:     []string{"?"},
+			Description: "Lists every command",
+			Run: func(args []string, out io.Writer) error {
+				for _, cmd := range c.Commands() {
+					_, _ = fmt.Fprintf(out, "%v %v - %v\n", cmd.Name, cmd.Usage, cmd.Description)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "list",
+			Usage:       "[server]",
+			Description: "Lists the players online and the server they are on",
+			Run: func(args []string, out io.Writer) error {
+				var lines []string
+				for _, ray := range s.Rays.All() {
+					server := s.Servers.Name(*ray.Remote().Addr())
+					if len(args) > 0 && !strings.EqualFold(server, args[0]) {
+						continue
+					}
+					lines = append(lines, fmt.Sprintf("%v (%v)", ray.IdentityData().DisplayName, server))
+				}
+				if len(args) > 0 {
+					_, _ = fmt.Fprintf(out, "%v players on %v\n", len(lines), args[0])
+				} else {
+					_, _ = fmt.Fprintf(out, "%v players online\n", len(lines))
+				}
+				for _, line := range lines {
+					_, _ = fmt.Fprintln(out, line)
+				}
+				return nil
+			},
+			Complete: c.completeServers,
+		},
+		{
+			Name:        "send",
+			Usage:       "<player> <server>",
+			Description: "Transfers a player to a server, by name or address:port",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) != 2 {
+					return ErrUsage
+				}
+				ray, err := c.player(args[0])
+				if err != nil {
+					return err
+				}
+				if err := s.TransferRay(ray, parseServer(args[1])); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(out, "Sent %v to %v\n", ray.IdentityData().DisplayName, args[1])
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 1 {
+					return c.completePlayers(args)
+				}
+				return c.completeServers(args)
+			},
+		},
+		{
+			Name:        "kick",
+			Usage:       "<player> [reason]",
+			Description: "Disconnects a player from the proxy",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 1 {
+					return ErrUsage
+				}
+				ray, err := c.player(args[0])
+				if err != nil {
+					return err
+				}
+				reason := "You were kicked from the network"
+				if len(args) > 1 {
+					reason = strings.Join(args[1:], " ")
+				}
+				s.DisconnectRay(ray, reason)
+				_, _ = fmt.Fprintf(out, "Kicked %v\n", ray.IdentityData().DisplayName)
+				return nil
+			},
+			Complete: c.completePlayers,
+		},
+		{
+			Name:        "broadcast",
+			Aliases:     []string{"say"},
+			Usage:       "<message>",
+			Description: "Sends a message to every player on the proxy",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) == 0 {
+					return ErrUsage
+				}
+				s.SendMessage(strings.Join(args, " "))
+				return nil
+			},
+		},
+		{
+			Name:        "title",
+			Usage:       "<audience> <title>[|subtitle]",
+			Description: "Shows a title to all, a server:<name>, a group:<name> or a player",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 2 {
+					return ErrUsage
+				}
+				title := &Title{Audience: parseAudience(args[0])}
+				parts := strings.SplitN(strings.Join(args[1:], " "), "|", 2)
+				title.Title = strings.TrimSpace(parts[0])
+				if len(parts) > 1 {
+					title.Subtitle = strings.TrimSpace(parts[1])
+				}
+				_, _ = fmt.Fprintf(out, "Showed the title to %v players\n", s.SendTitle(title))
+				return nil
+			},
+			Complete: c.completeAudience,
+		},
+		{
+			Name:        "actionbar",
+			Usage:       "<audience> <message>",
+			Description: "Shows a message above the hotbar of all, a server:<name>, a group:<name> or a player",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 2 {
+					return ErrUsage
+				}
+				n := s.SendActionBar(parseAudience(args[0]), strings.Join(args[1:], " "))
+				_, _ = fmt.Fprintf(out, "Showed the action bar to %v players\n", n)
+				return nil
+			},
+			Complete: c.completeAudience,
+		},
+		{
+			Name:        "bossbar",
+			Usage:       "<audience> <hide|health%> [title]",
+			Description: "Shows or hides the boss bar of the proxy for all, a server:<name>, a group:<name> or a player",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 2 {
+					return ErrUsage
+				}
+				bar := &BossBar{Audience: parseAudience(args[0])}
+				if strings.EqualFold(args[1], "hide") {
+					bar.Hide = true
+				} else {
+					health, err := strconv.ParseFloat(strings.TrimSuffix(args[1], "%"), 32)
+					if err != nil || health < 0 || health > 100 {
+						return ErrUsage
+					}
+					bar.Health = float32(health / 100)
+					bar.Title = strings.Join(args[2:], " ")
+				}
+				_, _ = fmt.Fprintf(out, "Set the boss bar for %v players\n", s.SetBossBar(bar))
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 2 {
+					return []string{"hide"}
+				}
+				return c.completeAudience(args)
+			},
+		},
+		{
+			Name:        "sound",
+			Usage:       "<audience> <sound> [volume] [pitch]",
+			Description: "Plays a sound to all, a server:<name>, a group:<name> or a player",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 2 || len(args) > 4 {
+					return ErrUsage
+				}
+				sound := &Sound{Audience: parseAudience(args[0]), Name: args[1], Volume: 1, Pitch: 1}
+				for i, f := range []*float32{&sound.Volume, &sound.Pitch} {
+					if len(args) > i+2 {
+						v, err := strconv.ParseFloat(args[i+2], 32)
+						if err != nil {
+							return ErrUsage
+						}
+						*f = float32(v)
+					}
+				}
+				_, _ = fmt.Fprintf(out, "Played %v to %v players\n", sound.Name, s.PlaySound(sound))
+				return nil
+			},
+			Complete: c.completeAudience,
+		},
+		{
+			Name:        "servers",
+			Description: "Lists the servers, whether they are up and the players on them",
+			Run: func(args []string, out io.Writer) error {
+				for _, entry := range s.ServerStatuses() {
+					state := "unknown"
+					if entry.Checked {
+						state = "down"
+						if entry.Up {
+							state = fmt.Sprintf("up, %v ping", entry.Latency)
+						}
+					}
+					_, _ = fmt.Fprintf(out, "%v (%v) %v, %v players on the proxy\n", entry.Name, entry.Address.ToString(),
+						state, len(s.Rays.OnServer(entry.Address)))
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "ban",
+			Usage:       "<xuid|name|ip> <value> [duration|perm] [reason]",
+			Description: "Bans a player from the network, a duration looks like 30m or 7d",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 2 {
+					return ErrUsage
+				}
+				ban := Ban{Kind: args[0], Value: args[1], Source: "console"}
+				if len(args) > 2 {
+					if args[2] != "perm" {
+						d, err := parseDuration(args[2])
+						if err != nil {
+							return err
+						}
+						ban.Expires = time.Now().Add(d)
+					}
+					ban.Reason = strings.Join(args[3:], " ")
+				}
+				if err := s.BanPlayer(ban); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(out, "Banned %v %v\n", ban.Kind, ban.Value)
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 1 {
+					return []string{BanXUID, BanName, BanIP}
+				}
+				if len(args) == 2 && args[0] == BanName {
+					return c.completePlayers(args)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "unban",
+			Usage:       "<xuid|name|ip> <value>",
+			Description: "Lifts a ban",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) != 2 {
+					return ErrUsage
+				}
+				removed, err := s.Bans.Remove(args[0], args[1])
+				if err != nil {
+					return err
+				}
+				if !removed {
+					return fmt.Errorf("%v %v isn't banned", args[0], args[1])
+				}
+				_, _ = fmt.Fprintf(out, "Unbanned %v %v\n", args[0], args[1])
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 1 {
+					return []string{BanXUID, BanName, BanIP}
+				}
+				var values []string
+				for _, ban := range s.Bans.All() {
+					if ban.Kind == args[0] {
+						values = append(values, ban.Value)
+					}
+				}
+				return values
+			},
+		},
+		{
+			Name:        "bans",
+			Description: "Lists the bans",
+			Run: func(args []string, out io.Writer) error {
+				for _, ban := range s.Bans.All() {
+					until := "permanent"
+					if !ban.Expires.IsZero() {
+						until = "until " + ban.Expires.Format(time.RFC1123)
+					}
+					_, _ = fmt.Fprintf(out, "%v %v (%v) by %v: %v\n", ban.Kind, ban.Value, until, ban.Source, ban.Reason)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "mute",
+			Usage:       "<player> [duration|perm] [reason]",
+			Description: "Stops a player from chatting on the whole network",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) < 1 {
+					return ErrUsage
+				}
+				mute := Mute{Player: args[0], Source: "console"}
+				if len(args) > 1 {
+					if args[1] != "perm" {
+						d, err := parseDuration(args[1])
+						if err != nil {
+							return err
+						}
+						mute.Expires = time.Now().Add(d)
+					}
+					mute.Reason = strings.Join(args[2:], " ")
+				}
+				if err := s.MutePlayer(mute); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(out, "Muted %v\n", mute.Player)
+				return nil
+			},
+			Complete: c.completePlayers,
+		},
+		{
+			Name:        "unmute",
+			Usage:       "<player>",
+			Description: "Lifts a mute",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) != 1 {
+					return ErrUsage
+				}
+				removed, err := s.Bans.Unmute(args[0])
+				if err != nil {
+					return err
+				}
+				if !removed {
+					return fmt.Errorf("%v isn't muted", args[0])
+				}
+				_, _ = fmt.Fprintf(out, "Unmuted %v\n", args[0])
+				return nil
+			},
+			Complete: func(args []string) []string {
+				var players []string
+				for _, mute := range s.Bans.Mutes() {
+					players = append(players, mute.Player)
+				}
+				return players
+			},
+		},
+		{
+			Name:        "whitelist",
+			Usage:       "<on|off|add|remove> [xuid|name]",
+			Description: "Enables or disables the whitelist or changes who is on it",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) == 0 {
+					return ErrUsage
+				}
+				var err error
+				switch strings.ToLower(args[0]) {
+				case "on", "off":
+					err = s.SetWhitelist(strings.ToLower(args[0]) == "on")
+				case "add", "remove":
+					if len(args) != 2 {
+						return ErrUsage
+					}
+					if strings.ToLower(args[0]) == "add" {
+						err = s.Bans.AddWhitelist(args[1])
+					} else {
+						err = s.Bans.RemoveWhitelist(args[1])
+					}
+				default:
+					return ErrUsage
+				}
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(out, "Updated the whitelist")
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 1 {
+					return []string{"on", "off", "add", "remove"}
+				}
+				return c.completePlayers(args)
+			},
+		},
+		{
+			Name:        "maintenance",
+			Usage:       "<on|off> [kick]",
+			Description: "Enables or disables maintenance, kick disconnects the players that aren't whitelisted",
+			Run: func(args []string, out io.Writer) error {
+				if len(args) == 0 {
+					_, _ = fmt.Fprintf(out, "Maintenance is %v\n", onOff(s.Maintenance.Enabled()))
+					return nil
+				}
+				var enabled bool
+				switch strings.ToLower(args[0]) {
+				case "on":
+					enabled = true
+				case "off":
+				default:
+					return ErrUsage
+				}
+				s.SetMaintenance(enabled, len(args) > 1 && strings.ToLower(args[1]) == "kick")
+				_, _ = fmt.Fprintf(out, "Maintenance is %v\n", onOff(enabled))
+				return nil
+			},
+			Complete: func(args []string) []string {
+				if len(args) == 1 {
+					return []string{"on", "off"}
+				}
+				return []string{"kick"}
+			},
+		},
+		{
+			Name:        "reload",
+			Description: "Reloads the servers, MOTDs and maintenance whitelist from the config and the bans from their file",
+			Run: func(args []string, out io.Writer) error {
+				if err := s.Reload(); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(out, "Reloaded the config")
+				return nil
+			},
+		},
+		{
+			Name:        "stop",
+			Aliases:     []string{"end"},
+			Description: "Shuts the proxy down",
+			Run: func(args []string, out io.Writer) error {
+				_, _ = fmt.Fprintln(out, "Stopping Sun!")
+				go c.Stop()
+				return nil
+			},
+		},
+	} {
+		_ = c.Register(cmd)
+	}
+}
+
+/*
+Returns the player with the name or XUID passed.
+*/
+func (c *Console) player(name string) (*Ray, error) {
+	if ray, ok := c.sun.Rays.ByName(name); ok {
+		return ray, nil
+	}
+	if ray, ok := c.sun.Rays.ByXUID(name); ok {
+		return ray, nil
+	}
+	return nil, fmt.Errorf("player %v is not online", name)
+}
+
+func (c *Console) completePlayers([]string) []string {
+	var names []string
+	for _, ray := range c.sun.Rays.All() {
+		names = append(names, ray.IdentityData().DisplayName)
+	}
+	return names
+}
+
+func (c *Console) completeServers([]string) []string {
+	var names []string
+	for _, server := range c.sun.Servers.All() {
+		names = append(names, server.Name)
+	}
+	return names
+}
+
+/*
+Completes the audience of a command, which is all, server:<name>, group:<name> or the name of a player.
+*/
+func (c *Console) completeAudience(args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	names := append([]string{"all"}, c.completePlayers(args)...)
+	groups := make(map[string]bool)
+	for _, server := range c.sun.Servers.All() {
+		names = append(names, "server:"+server.Name)
+		if server.Group != "" && !groups[strings.ToLower(server.Group)] {
+			groups[strings.ToLower(server.Group)] = true
+			names = append(names, "group:"+server.Group)
+		}
+	}
+	return names
+}
+
+/*
+Parses the audience of a command, all is every player on the proxy.
+*/
+func parseAudience(audience string) Audience {
+	switch {
+	case strings.EqualFold(audience, "all"):
+		return Audience{}
+	case strings.HasPrefix(strings.ToLower(audience), "server:"):
+		return Audience{Servers: []string{audience[len("server:"):]}}
+	case strings.HasPrefix(strings.ToLower(audience), "group:"):
+		return Audience{Group: audience[len("group:"):]}
+	}
+	return Audience{Player: audience}
+}
+
+/*
+Parses a server name or an address:port into an IpAddr, a name has port 0.
+*/
+func parseServer(server string) IpAddr {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return IpAddr{Address: server}
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return IpAddr{Address: server}
+	}
+	return IpAddr{Address: host, Port: uint16(p)}
+}
+
+/*
+Parses a duration like time.ParseDuration does, with d for days.
+*/
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %v", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,41 @@
+package sun
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	s := &Sun{Rays: NewRayRegistry(), Servers: NewServerRegistry([]Server{{Name: "hub"}, {Name: "hcf"}, {Name: "skyblock"}})}
+	c := NewConsole(s)
+	var got []string
+	if err := c.Register(Command{Name: "echo", Run: func(args []string, out io.Writer) error {
+		got = args
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Register(Command{Name: "other", Aliases: []string{"ECHO"}}); err == nil {
+		t.Fatal("expected registering a taken alias to fail")
+	}
+	c.Execute("echo a  b", ioutil.Discard)
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("expected the arguments to be passed, got %v", got)
+	}
+
+	out := &bytes.Buffer{}
+	c.Execute("send", out)
+	if !strings.HasPrefix(out.String(), "Usage: send") {
+		t.Fatalf("expected the usage to be printed, got %q", out.String())
+	}
+	if matches := c.Complete("ec"); !reflect.DeepEqual(matches, []string{"echo"}) {
+		t.Fatalf("expected echo to be completed, got %v", matches)
+	}
+	if matches := c.Complete("list h"); !reflect.DeepEqual(matches, []string{"hcf", "hub"}) {
+		t.Fatalf("expected the servers to be completed, got %v", matches)
+	}
+}
@@ -0,0 +1,287 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft"
+	"net"
+	"sort"
+	"sync"
+)
+
+/*
+Priority decides the order handlers of an event run in. Handlers with a lower priority run first, so the handler
+with the highest priority has the final say. Handlers of the same priority run in the order they were added.
+*/
+type Priority int
+
+const (
+	PriorityLowest Priority = iota
+	PriorityLow
+	PriorityNormal
+	PriorityHigh
+	PriorityHighest
+	//PriorityMonitor handlers run last and should only look at the outcome of an event, not change it.
+	PriorityMonitor
+)
+
+/*
+PreLoginEvent is called when a player joined the proxy, before they are connected to a hub. Cancelling it
+disconnects the player with the KickMessage.
+*/
+type PreLoginEvent struct {
+	Conn        *minecraft.Conn
+	Cancelled   bool
+	KickMessage string
+}
+
+/*
+Cancels the login, disconnecting the player with the message passed.
+*/
+func (e *PreLoginEvent) Cancel(message string) {
+	e.Cancelled = true
+	e.KickMessage = message
+}
+
+/*
+PostLoginEvent is called once a player spawned on their first server.
+*/
+type PostLoginEvent struct {
+	Ray *Ray
+}
+
+/*
+ServerPreConnectEvent is called before a player is connected to a server, both when joining and when being
+transferred. Target may be changed to connect the player to another server, cancelling it fails the connection.
+*/
+type ServerPreConnectEvent struct {
+	Ray       *Ray
+	Target    IpAddr
+	Cancelled bool
+}
+
+/*
+ServerConnectedEvent is called once a player is on a new server, Previous is empty if they just joined.
+*/
+type ServerConnectedEvent struct {
+	Ray      *Ray
+	Server   IpAddr
+	Previous IpAddr
+}
+
+/*
+TransferFailedEvent is called when transferring a player failed, the player is still on their old server.
+*/
+type TransferFailedEvent struct {
+	Ray    *Ray
+	Target IpAddr
+	Err    error
+}
+
+/*
+DisconnectEvent is called once a player left the proxy.
+*/
+type DisconnectEvent struct {
+	Ray *Ray
+}
+
+/*
+PlanetConnectedEvent is called once a planet authenticated.
+*/
+type PlanetConnectedEvent struct {
+	Planet *Planet
+}
+
+/*
+PlanetAuthFailedEvent is called when a planet sent the wrong key or is on cooldown.
+*/
+type PlanetAuthFailedEvent struct {
+	Addr   net.Addr
+	Reason string
+}
+
+type handler struct {
+	priority Priority
+	id       uint64
+	fn       interface{}
+}
+
+/*
+handlers holds the handlers of one event sorted by priority.
+*/
+type handlers struct {
+	mu     sync.RWMutex
+	nextID uint64
+	list   []handler
+}
+
+func (h *handlers) add(priority Priority, fn interface{}) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	id := h.nextID
+	h.list = append(h.list, handler{priority: priority, id: id, fn: fn})
+	sort.SliceStable(h.list, func(i, j int) bool {
+		return h.list[i].priority < h.list[j].priority
+	})
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, handler := range h.list {
+			if handler.id == id {
+				h.list = append(h.list[:i:i], h.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+/*
+Returns the handlers at this moment, so they can be called without holding the lock.
+*/
+func (h *handlers) snapshot() []interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	fns := make([]interface{}, len(h.list))
+	for i, handler := range h.list {
+		fns[i] = handler.fn
+	}
+	return fns
+}
+
+/*
+Events lets embedders react to what happens on the proxy. Every On method returns a function that removes the
+handler again, handlers may be added and removed from any routine. Handlers are called on the routine of the
+player or planet, so they should not block.
+*/
+type Events struct {
+	preLogin         handlers
+	postLogin        handlers
+	serverPreConnect handlers
+	serverConnected  handlers
+	transferFailed   handlers
+	disconnect       handlers
+	planetConnected  handlers
+	planetAuthFailed handlers
+}
+
+/*
+Returns new Events without handlers.
+*/
+func NewEvents() *Events {
+	return &Events{}
+}
+
+func (e *Events) OnPreLogin(priority Priority, h func(e *PreLoginEvent)) func() {
+	return e.preLogin.add(priority, h)
+}
+
+func (e *Events) OnPostLogin(priority Priority, h func(e *PostLoginEvent)) func() {
+	return e.postLogin.add(priority, h)
+}
+
+func (e *Events) OnServerPreConnect(priority Priority, h func(e *ServerPreConnectEvent)) func() {
+	return e.serverPreConnect.add(priority, h)
+}
+
+func (e *Events) OnServerConnected(priority Priority, h func(e *ServerConnectedEvent)) func() {
+	return e.serverConnected.add(priority, h)
+}
+
+func (e *Events) OnTransferFailed(priority Priority, h func(e *TransferFailedEvent)) func() {
+	return e.transferFailed.add(priority, h)
+}
+
+func (e *Events) OnDisconnect(priority Priority, h func(e *DisconnectEvent)) func() {
+	return e.disconnect.add(priority, h)
+}
+
+func (e *Events) OnPlanetConnected(priority Priority, h func(e *PlanetConnectedEvent)) func() {
+	return e.planetConnected.add(priority, h)
+}
+
+func (e *Events) OnPlanetAuthFailed(priority Priority, h func(e *PlanetAuthFailedEvent)) func() {
+	return e.planetAuthFailed.add(priority, h)
+}
+
+func (e *Events) firePreLogin(ev *PreLoginEvent) {
+	for _, h := range e.preLogin.snapshot() {
+		h.(func(*PreLoginEvent))(ev)
+	}
+}
+
+func (e *Events) firePostLogin(ev *PostLoginEvent) {
+	for _, h := range e.postLogin.snapshot() {
+		h.(func(*PostLoginEvent))(ev)
+	}
+}
+
+func (e *Events) fireServerPreConnect(ev *ServerPreConnectEvent) {
+	for _, h := range e.serverPreConnect.snapshot() {
+		h.(func(*ServerPreConnectEvent))(ev)
+	}
+}
+
+func (e *Events) fireServerConnected(ev *ServerConnectedEvent) {
+	for _, h := range e.serverConnected.snapshot() {
+		h.(func(*ServerConnectedEvent))(ev)
+	}
+}
+
+func (e *Events) fireTransferFailed(ev *TransferFailedEvent) {
+	for _, h := range e.transferFailed.snapshot() {
+		h.(func(*TransferFailedEvent))(ev)
+	}
+}
+
+func (e *Events) fireDisconnect(ev *DisconnectEvent) {
+	for _, h := range e.disconnect.snapshot() {
+		h.(func(*DisconnectEvent))(ev)
+	}
+}
+
+func (e *Events) firePlanetConnected(ev *PlanetConnectedEvent) {
+	for _, h := range e.planetConnected.snapshot() {
+		h.(func(*PlanetConnectedEvent))(ev)
+	}
+}
+
+func (e *Events) firePlanetAuthFailed(ev *PlanetAuthFailedEvent) {
+	for _, h := range e.planetAuthFailed.snapshot() {
+		h.(func(*PlanetAuthFailedEvent))(ev)
+	}
+}
@@ -0,0 +1,60 @@
+package sun
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEventsPriority(t *testing.T) {
+	e := NewEvents()
+	var order []string
+	e.OnPreLogin(PriorityMonitor, func(ev *PreLoginEvent) {
+		order = append(order, "monitor")
+		if !ev.Cancelled {
+			t.Error("expected the monitor to see the cancelled event")
+		}
+	})
+	e.OnPreLogin(PriorityHigh, func(ev *PreLoginEvent) {
+		order = append(order, "high")
+		ev.Cancel("no")
+	})
+	remove := e.OnPreLogin(PriorityLow, func(ev *PreLoginEvent) {
+		order = append(order, "low")
+	})
+	e.OnPreLogin(PriorityLow, func(ev *PreLoginEvent) {
+		order = append(order, "low2")
+	})
+	e.firePreLogin(&PreLoginEvent{})
+	if !reflect.DeepEqual(order, []string{"low", "low2", "high", "monitor"}) {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	remove()
+	order = nil
+	e.firePreLogin(&PreLoginEvent{})
+	if !reflect.DeepEqual(order, []string{"low2", "high", "monitor"}) {
+		t.Fatalf("expected the removed handler not to run, got %v", order)
+	}
+}
+
+func TestEventsConcurrent(t *testing.T) {
+	e := NewEvents()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			remove := e.OnDisconnect(PriorityNormal, func(*DisconnectEvent) {})
+			remove()
+		}()
+		go func() {
+			defer wg.Done()
+			e.fireDisconnect(&DisconnectEvent{})
+		}()
+	}
+	wg.Wait()
+	if len(e.disconnect.snapshot()) != 0 {
+		t.Fatal("expected every handler to be removed")
+	}
+}
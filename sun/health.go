@@ -0,0 +1,270 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/go-raknet"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ServerHealth is the result of pinging a backend server.
+*/
+type ServerHealth struct {
+	//Up is false once the server failed enough pings in a row.
+	Up bool
+	//Latency is the time the last successful ping took.
+	Latency time.Duration
+	//MOTD is the server name the server responded with.
+	MOTD string
+	//PlayerCount and MaxPlayers are the player counts the server responded with.
+	PlayerCount int
+	MaxPlayers  int
+	//LastPing is the time the server was last pinged.
+	LastPing time.Time
+
+	successes int
+	failures  int
+	known     bool
+}
+
+/*
+HealthChecker pings every known backend over RakNet in the background and keeps track of which are up.
+A server is only marked down after DownThreshold failed pings in a row and up again after UpThreshold
+successful ones, so a single lost ping doesn't move players around.
+*/
+type HealthChecker struct {
+	Interval      time.Duration
+	Timeout       time.Duration
+	UpThreshold   int
+	DownThreshold int
+
+	//targets returns the addresses that should be pinged.
+	targets func() []IpAddr
+
+	mu     sync.RWMutex
+	health map[IpAddr]*ServerHealth
+}
+
+/*
+Returns a new HealthChecker pinging the addresses returned by targets every interval.
+*/
+func NewHealthChecker(targets func() []IpAddr, interval, timeout time.Duration, up, down int) *HealthChecker {
+	return &HealthChecker{
+		Interval:      interval,
+		Timeout:       timeout,
+		UpThreshold:   up,
+		DownThreshold: down,
+		targets:       targets,
+		health:        make(map[IpAddr]*ServerHealth),
+	}
+}
+
+/*
+Pings every target each Interval until close is closed.
+*/
+func (h *HealthChecker) run(close <-chan struct{}) {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		h.Check()
+		select {
+		case <-close:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Pings every target once and waits for all of them to respond or time out.
+*/
+func (h *HealthChecker) Check() {
+	targets := h.targets()
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for _, addr := range targets {
+		go func(addr IpAddr) {
+			defer wg.Done()
+			start := time.Now()
+			pong, err := raknet.PingTimeout(addr.ToString(), h.Timeout)
+			h.record(addr, start, time.Since(start), pong, err)
+		}(addr)
+	}
+	wg.Wait()
+
+	//forget servers that are no longer targets
+	known := make(map[IpAddr]bool, len(targets))
+	for _, addr := range targets {
+		known[addr] = true
+	}
+	h.mu.Lock()
+	for addr := range h.health {
+		if !known[addr] {
+			delete(h.health, addr)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (h *HealthChecker) record(addr IpAddr, at time.Time, latency time.Duration, pong []byte, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health, ok := h.health[addr]
+	if !ok {
+		health = &ServerHealth{}
+		h.health[addr] = health
+	}
+	health.LastPing = at
+	if err != nil {
+		health.successes = 0
+		health.failures++
+		if !health.known || health.failures >= h.DownThreshold {
+			health.Up = false
+		}
+		health.known = true
+		return
+	}
+	health.failures = 0
+	health.successes++
+	if !health.known || health.successes >= h.UpThreshold {
+		health.Up = true
+	}
+	health.known = true
+	health.Latency = latency
+	health.MOTD, health.PlayerCount, health.MaxPlayers = parsePong(pong)
+}
+
+/*
+Returns the health of the server with the address passed, false if it wasn't pinged yet.
+*/
+func (h *HealthChecker) Status(addr IpAddr) (ServerHealth, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	health, ok := h.health[addr]
+	if !ok {
+		return ServerHealth{}, false
+	}
+	return *health, true
+}
+
+/*
+Returns false only if the server with the address passed is known to be down, servers that weren't pinged yet
+are assumed to be up.
+*/
+func (h *HealthChecker) Up(addr IpAddr) bool {
+	health, ok := h.Status(addr)
+	return !ok || health.Up
+}
+
+/*
+Returns the health of every server that was pinged.
+*/
+func (h *HealthChecker) All() map[IpAddr]ServerHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	all := make(map[IpAddr]ServerHealth, len(h.health))
+	for addr, health := range h.health {
+		all[addr] = *health
+	}
+	return all
+}
+
+/*
+Returns the MOTD and player counts in a pong, which looks like
+MCPE;motd;protocol;version;players;max players;server id;sub motd;game mode;...
+*/
+func parsePong(pong []byte) (motd string, players, maxPlayers int) {
+	frag := splitPong(string(pong))
+	if len(frag) > 1 {
+		motd = frag[1]
+	}
+	if len(frag) > 5 {
+		players, _ = strconv.Atoi(frag[4])
+		maxPlayers, _ = strconv.Atoi(frag[5])
+	}
+	return
+}
+
+/*
+Splits the pong data by ;, taking escaped semicolons into account.
+*/
+func splitPong(s string) []string {
+	var tokens []string
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			tokens = append(tokens, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(tokens, b.String())
+}
+
+/*
+Returns the addresses of every backend the proxy knows of: the Servers, the default hub and the Fallbacks.
+*/
+func (s *Sun) backends() []IpAddr {
+	seen := make(map[IpAddr]bool)
+	var addrs []IpAddr
+	add := func(addr IpAddr) {
+		if addr != (IpAddr{}) && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	for _, server := range s.Servers.All() {
+		add(server.Address)
+	}
+	add(s.Hub)
+	for _, addr := range s.Fallbacks {
+		add(addr)
+	}
+	return addrs
+}
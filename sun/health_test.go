@@ -0,0 +1,91 @@
+package sun
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitPong(t *testing.T) {
+	for _, c := range []struct {
+		pong string
+		want []string
+	}{
+		{"", []string{""}},
+		{"MCPE", []string{"MCPE"}},
+		{"MCPE;", []string{"MCPE", ""}},
+		{"MCPE;a\\;b;c", []string{"MCPE", "a;b", "c"}},
+		{"MCPE;a\\\\;b", []string{"MCPE", "a\\", "b"}},
+		{"MCPE;a\\", []string{"MCPE", "a"}},
+		{";;", []string{"", "", ""}},
+	} {
+		if got := splitPong(c.pong); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitPong(%q) = %q, expected %q", c.pong, got, c.want)
+		}
+	}
+}
+
+func TestParsePong(t *testing.T) {
+	for _, c := range []struct {
+		pong                string
+		motd                string
+		players, maxPlayers int
+	}{
+		{"", "", 0, 0},
+		{"MCPE", "", 0, 0},
+		{"MCPE;Sun", "Sun", 0, 0},
+		{"MCPE;Sun;390;1.14.60;5", "Sun", 0, 0},
+		{"MCPE;Sun;390;1.14.60;five;twenty", "Sun", 0, 0},
+		{"MCPE;Sun;390;1.14.60;5;20", "Sun", 5, 20},
+		{"MCPE;Sun\\;Proxy;390;1.14.60;5;20;123;Sub;Survival", "Sun;Proxy", 5, 20},
+		{"\x00\xff;\xfe", "\uFFFD", 0, 0},
+	} {
+		motd, players, maxPlayers := parsePong([]byte(c.pong))
+		if motd != c.motd || players != c.players || maxPlayers != c.maxPlayers {
+			t.Errorf("parsePong(%q) = %q, %v, %v, expected %q, %v, %v", c.pong, motd, players, maxPlayers, c.motd, c.players, c.maxPlayers)
+		}
+	}
+}
+
+func TestHealthCheckerRecord(t *testing.T) {
+	addr := IpAddr{Address: "127.0.0.1", Port: 19133}
+	pong := []byte("MCPE;Sun;390;1.14.60;5;20")
+	errPing := errors.New("timeout")
+
+	for _, c := range []struct {
+		name  string
+		pings []bool
+		up    []bool
+	}{
+		{"first ping up", []bool{true}, []bool{true}},
+		{"first ping down", []bool{false}, []bool{false}},
+		{"down after threshold", []bool{true, false, false, false}, []bool{true, true, true, false}},
+		{"up after threshold", []bool{false, true, true}, []bool{false, false, true}},
+		{"flapping stays up", []bool{true, false, true, false, false, true, false}, []bool{true, true, true, true, true, true, true}},
+		{"flapping stays down", []bool{false, true, false, true, false, true, true}, []bool{false, false, false, false, false, false, true}},
+	} {
+		h := NewHealthChecker(nil, time.Second, time.Second, 2, 3)
+		for i, ok := range c.pings {
+			if ok {
+				h.record(addr, time.Now(), time.Millisecond, pong, nil)
+			} else {
+				h.record(addr, time.Now(), 0, nil, errPing)
+			}
+			if h.Up(addr) != c.up[i] {
+				t.Fatalf("%v: expected up to be %v after ping %v", c.name, c.up[i], i+1)
+			}
+		}
+	}
+
+	h := NewHealthChecker(nil, time.Second, time.Second, 2, 3)
+	if _, ok := h.Status(addr); ok || !h.Up(addr) {
+		t.Fatal("expected a server that wasn't pinged to be unknown and assumed up")
+	}
+	h.record(addr, time.Now(), time.Millisecond, pong, nil)
+	h.record(addr, time.Now(), 0, nil, errPing)
+	health, _ := h.Status(addr)
+	if health.MOTD != "Sun" || health.PlayerCount != 5 || health.MaxPlayers != 20 || health.Latency != time.Millisecond {
+		t.Fatalf("expected a failed ping to keep the last pong, got %#v", health)
+	}
+}
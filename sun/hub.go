@@ -0,0 +1,291 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+HubStrategy picks the hub a player is sent to out of the hubs that are up.
+*/
+type HubStrategy interface {
+	//Select returns the index of the server in hubs the player should be sent to, hubs is never empty.
+	Select(ray *Ray, hubs []Server) int
+}
+
+/*
+RoundRobin sends every player to the next hub in turn.
+*/
+type RoundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (r *RoundRobin) Select(_ *Ray, hubs []Server) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	return r.next % len(hubs)
+}
+
+/*
+LeastPlayers sends every player to the hub with the least players on it, as counted by Count.
+*/
+type LeastPlayers struct {
+	Count func(addr IpAddr) int
+}
+
+func (l LeastPlayers) Select(_ *Ray, hubs []Server) int {
+	best, bestCount := 0, -1
+	for i, hub := range hubs {
+		if count := l.Count(hub.Address); bestCount == -1 || count < bestCount {
+			best, bestCount = i, count
+		}
+	}
+	return best
+}
+
+/*
+Random sends every player to a random hub.
+*/
+type Random struct{}
+
+func (Random) Select(_ *Ray, hubs []Server) int {
+	return rand.Intn(len(hubs))
+}
+
+/*
+XUIDHash sends a player to the same hub every time as long as the hubs stay the same, only the players of a hub
+that went down or was added are moved around. Players without an XUID are hashed by their identity instead.
+*/
+type XUIDHash struct{}
+
+func (XUIDHash) Select(ray *Ray, hubs []Server) int {
+	key := ray.IdentityData().XUID
+	if key == "" {
+		key = ray.IdentityData().Identity
+	}
+	//rendezvous hashing, the hub with the highest score for the key wins
+	best, bestScore := 0, uint64(0)
+	for i, hub := range hubs {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key + "|" + hub.Address.ToString()))
+		if score := h.Sum64(); score >= bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+/*
+Returns the HubStrategy with the name passed, the Sun is used by strategies that need to count players.
+*/
+func NewHubStrategy(name string, s *Sun) (HubStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", "round-robin":
+		return &RoundRobin{}, nil
+	case "least-players":
+		return LeastPlayers{Count: s.ServerCounts.Count}, nil
+	case "random":
+		return Random{}, nil
+	case "hash":
+		return XUIDHash{}, nil
+	}
+	return nil, fmt.Errorf("unknown hub strategy %v", name)
+}
+
+/*
+HubBalancer spreads players over the servers in the hub group, skipping hubs that recently failed to dial.
+*/
+type HubBalancer struct {
+	//Group is the group of the Servers that are hubs, if no server is in it the default hub is used.
+	Group string
+	//Strategy picks the hub every player is sent to.
+	Strategy HubStrategy
+	//Cooldown is how long a hub that failed to dial is skipped for.
+	Cooldown time.Duration
+	//Health, if set, is consulted so hubs that are down are skipped.
+	Health *HealthChecker
+
+	servers    *ServerRegistry
+	defaultHub IpAddr
+
+	mu        sync.Mutex
+	unhealthy map[IpAddr]time.Time
+}
+
+/*
+Returns a new HubBalancer for the hubs in the group passed, falling back to the default hub.
+*/
+func NewHubBalancer(servers *ServerRegistry, group string, defaultHub IpAddr, strategy HubStrategy, cooldown time.Duration) *HubBalancer {
+	return &HubBalancer{
+		Group:      group,
+		Strategy:   strategy,
+		Cooldown:   cooldown,
+		servers:    servers,
+		defaultHub: defaultHub,
+		unhealthy:  make(map[IpAddr]time.Time),
+	}
+}
+
+/*
+Returns all the hubs, sorted by name so strategies see them in the same order every time.
+*/
+func (b *HubBalancer) Hubs() []Server {
+	var hubs []Server
+	if b.Group != "" {
+		for _, server := range b.servers.All() {
+			if strings.EqualFold(server.Group, b.Group) {
+				hubs = append(hubs, server)
+			}
+		}
+	}
+	if len(hubs) == 0 {
+		return []Server{{Name: b.servers.Name(b.defaultHub), Address: b.defaultHub}}
+	}
+	sort.Slice(hubs, func(i, j int) bool {
+		return hubs[i].Name < hubs[j].Name
+	})
+	return hubs
+}
+
+/*
+Returns true if the address passed is one of the hubs.
+*/
+func (b *HubBalancer) IsHub(addr IpAddr) bool {
+	for _, hub := range b.Hubs() {
+		if hub.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Marks the hub with the address passed as down, so it is skipped for the Cooldown. Addresses that are not hubs are
+ignored, so failing to dial any other server doesn't fill the map.
+*/
+func (b *HubBalancer) MarkUnhealthy(addr IpAddr) {
+	if !b.IsHub(addr) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unhealthy[addr] = time.Now().Add(b.Cooldown)
+}
+
+/*
+Returns false if the server with the address passed failed to dial within the Cooldown or is down.
+*/
+func (b *HubBalancer) Healthy(addr IpAddr) bool {
+	if b.Health != nil && !b.Health.Up(addr) {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.unhealthy[addr]
+	if ok && time.Now().After(until) {
+		delete(b.unhealthy, addr)
+		return true
+	}
+	return !ok
+}
+
+/*
+Returns the hubs in the order they should be tried for the player passed: the healthy hubs in the order the
+Strategy picks them, followed by the unhealthy ones as a last resort.
+*/
+func (b *HubBalancer) Order(ray *Ray) []IpAddr {
+	var healthy, unhealthy []Server
+	for _, hub := range b.Hubs() {
+		if b.Healthy(hub.Address) {
+			healthy = append(healthy, hub)
+		} else {
+			unhealthy = append(unhealthy, hub)
+		}
+	}
+	order := make([]IpAddr, 0, len(healthy)+len(unhealthy))
+	if len(healthy) > 0 {
+		//the rest of the healthy hubs follow the pick in turn
+		i := b.Strategy.Select(ray, healthy)
+		for _, hub := range append(healthy[i:], healthy[:i]...) {
+			order = append(order, hub.Address)
+		}
+	}
+	for _, hub := range unhealthy {
+		order = append(order, hub.Address)
+	}
+	return order
+}
+
+/*
+Dials the hubs for a new player in the order picked by the Hubs, marking every hub that fails as unhealthy.
+*/
+func (s *Sun) dialHub(ray *Ray) (*Remote, error) {
+	err := errors.New("there are no hubs")
+	for _, addr := range s.Hubs.Order(ray) {
+		ev := &ServerPreConnectEvent{Ray: ray, Target: addr}
+		s.Events.fireServerPreConnect(ev)
+		if ev.Cancelled {
+			err = fmt.Errorf("connecting to %v was cancelled", addr.ToString())
+			continue
+		}
+		if target, ok := s.Servers.Resolve(ev.Target); ok {
+			addr = target
+		}
+		var conn *minecraft.Conn
+		s.ServerCounts.Inc(addr)
+		conn, err = s.dialer(ray, ray.conn.IdentityData(), addr).DialTimeout("raknet", addr.ToString(), 10*time.Second)
+		if err == nil {
+			return &Remote{conn: conn, addr: addr, counter: s.ServerCounts}, nil
+		}
+		s.ServerCounts.Dec(addr)
+		log.Printf("error dialing hub %v for player %v: %v\n", addr.ToString(), ray.IdentityData().DisplayName, err)
+		s.Hubs.MarkUnhealthy(addr)
+	}
+	return nil, err
+}
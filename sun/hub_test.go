@@ -0,0 +1,93 @@
+package sun
+
+import (
+	"testing"
+	"time"
+)
+
+func testBalancer(strategy HubStrategy) *HubBalancer {
+	servers := NewServerRegistry([]Server{
+		{Name: "lobby-1", Address: IpAddr{Address: "127.0.0.1", Port: 19133}, Group: "lobby"},
+		{Name: "lobby-2", Address: IpAddr{Address: "127.0.0.1", Port: 19134}, Group: "lobby"},
+		{Name: "lobby-3", Address: IpAddr{Address: "127.0.0.1", Port: 19135}, Group: "lobby"},
+		{Name: "bedwars", Address: IpAddr{Address: "127.0.0.1", Port: 19136}, Group: "games"},
+	})
+	return NewHubBalancer(servers, "lobby", IpAddr{Address: "127.0.0.1", Port: 19132}, strategy, time.Minute)
+}
+
+func TestHubBalancerRoundRobin(t *testing.T) {
+	b := testBalancer(&RoundRobin{})
+	seen := make(map[IpAddr]int)
+	for i := 0; i < 9; i++ {
+		order := b.Order(testRay(i, IpAddr{}))
+		if len(order) != 3 {
+			t.Fatalf("expected 3 hubs, got %v", order)
+		}
+		seen[order[0]]++
+	}
+	for addr, n := range seen {
+		if n != 3 {
+			t.Fatalf("expected every hub to be picked 3 times, %v was picked %v times", addr.ToString(), n)
+		}
+	}
+}
+
+func TestHubBalancerUnhealthy(t *testing.T) {
+	b := testBalancer(&RoundRobin{})
+	down := IpAddr{Address: "127.0.0.1", Port: 19134}
+	b.MarkUnhealthy(down)
+	for i := 0; i < 3; i++ {
+		order := b.Order(testRay(i, IpAddr{}))
+		if order[0] == down || order[len(order)-1] != down {
+			t.Fatalf("expected the unhealthy hub to be tried last, got %v", order)
+		}
+	}
+	game := IpAddr{Address: "127.0.0.1", Port: 19136}
+	b.MarkUnhealthy(game)
+	if _, ok := b.unhealthy[game]; ok {
+		t.Fatal("expected a server outside the hub pool not to be marked")
+	}
+}
+
+func TestHubBalancerXUIDHash(t *testing.T) {
+	b := testBalancer(XUIDHash{})
+	ray := testRay(1, IpAddr{})
+	first := b.Order(ray)[0]
+	for i := 0; i < 5; i++ {
+		if got := b.Order(ray)[0]; got != first {
+			t.Fatalf("expected the same hub every time, got %v and %v", first.ToString(), got.ToString())
+		}
+	}
+	//removing another hub must not move the player
+	for _, server := range b.Hubs() {
+		if server.Address != first {
+			b.servers.Remove(server.Name)
+			break
+		}
+	}
+	if got := b.Order(ray)[0]; got != first {
+		t.Fatalf("expected the player to stay on %v, got %v", first.ToString(), got.ToString())
+	}
+}
+
+func TestHubBalancerLeastPlayers(t *testing.T) {
+	counts := NewServerCounter()
+	b := testBalancer(LeastPlayers{Count: counts.Count})
+	first, second := IpAddr{Address: "127.0.0.1", Port: 19133}, IpAddr{Address: "127.0.0.1", Port: 19134}
+	//players still being dialed count as well
+	counts.Inc(first)
+	counts.Inc(second)
+	counts.Inc(second)
+	if got := b.Order(testRay(1, IpAddr{}))[0]; got != (IpAddr{Address: "127.0.0.1", Port: 19135}) {
+		t.Fatalf("expected the empty hub to be picked, got %v", got.ToString())
+	}
+	counts.Inc(IpAddr{Address: "127.0.0.1", Port: 19135})
+	counts.Inc(IpAddr{Address: "127.0.0.1", Port: 19135})
+	counts.Dec(first)
+	if got := b.Order(testRay(1, IpAddr{}))[0]; got != first {
+		t.Fatalf("expected the hub a player left to be picked, got %v", got.ToString())
+	}
+	if _, ok := counts.counts[first]; ok {
+		t.Fatal("expected a server without players to be removed from the counts")
+	}
+}
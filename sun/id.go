@@ -42,6 +42,9 @@ The RakNet connection or rather mcpe packet ids
 const (
 	IDRayTransfer = iota + 0xFA
 	IDRayText
+	IDRayTitle
+	IDRayBossBar
+	IDRaySound
 )
 
 const (
@@ -51,4 +54,43 @@ const (
 	IDPlanetTransferResponse
 	IDPlanetText
 	IDPlanetTextResponse
-)
\ No newline at end of file
+	IDPlanetRegisterServer
+	IDPlanetUnregisterServer
+	IDPlanetServerStatusRequest
+	IDPlanetServerStatusResponse
+	IDPlanetPlayerCount
+	IDPlanetSetMOTD
+	IDPlanetMaintenance
+	IDPlanetBan
+	IDPlanetUnban
+	IDPlanetWhitelist
+	IDPlanetPermission
+	IDPlanetMute
+	IDPlanetUnmute
+	IDPlanetChannel
+	IDPlanetChannelMember
+	IDPlanetTitle
+	IDPlanetBossBar
+	IDPlanetSound
+	IDPlanetAuthResponse
+	IDPlanetRegisterServerResponse
+	IDPlanetPeerCountRequest
+	IDPlanetPeerCountResponse
+)
+
+/**
+The status codes sent in the PlanetTransferResponse and PlanetTextResponse packets
+*/
+const (
+	PlanetStatusOK = iota
+	PlanetStatusPlayerNotFound
+	PlanetStatusAlreadyTransferring
+	PlanetStatusDialFailed
+	PlanetStatusSpawnFailed
+	PlanetStatusDisconnected
+	PlanetStatusServerNotFound
+	PlanetStatusServerDown
+	PlanetStatusCancelled
+	PlanetStatusInvalidServer
+	PlanetStatusServerExists
+)
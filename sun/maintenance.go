@@ -0,0 +1,169 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Maintenance closes the proxy to everyone but a whitelist of XUIDs and names while it is enabled.
+*/
+type Maintenance struct {
+	//Message is the disconnect message players that aren't whitelisted get.
+	Message string
+	//MOTD is the MOTD template shown in the server list while maintenance is enabled.
+	MOTD string
+	//MatchNames allows whitelisted names to join, see matchesPlayer.
+	MatchNames bool
+
+	mu        sync.RWMutex
+	enabled   bool
+	whitelist map[string]bool
+}
+
+/*
+Returns a new Maintenance with the XUIDs and names in whitelist allowed to join.
+*/
+func NewMaintenance(enabled bool, message, motd string, whitelist []string) *Maintenance {
+	m := &Maintenance{Message: message, MOTD: motd, enabled: enabled, whitelist: make(map[string]bool)}
+	for _, entry := range whitelist {
+		m.whitelist[strings.ToLower(entry)] = true
+	}
+	return m
+}
+
+/*
+Returns true if maintenance is enabled.
+*/
+func (m *Maintenance) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+/*
+Returns true if the player with the identity passed may join, which is always the case if maintenance is disabled.
+*/
+func (m *Maintenance) Allowed(identity login.IdentityData) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.enabled {
+		return true
+	}
+	return (identity.XUID != "" && m.whitelist[identity.XUID]) || (m.MatchNames && m.whitelist[strings.ToLower(identity.DisplayName)])
+}
+
+/*
+Allows the XUID or name passed to join during maintenance.
+*/
+func (m *Maintenance) AddWhitelist(entry string) {
+	m.mu.Lock()
+	m.whitelist[strings.ToLower(entry)] = true
+	m.mu.Unlock()
+}
+
+/*
+Removes an XUID or name added with AddWhitelist.
+*/
+func (m *Maintenance) RemoveWhitelist(entry string) {
+	m.mu.Lock()
+	delete(m.whitelist, strings.ToLower(entry))
+	m.mu.Unlock()
+}
+
+/*
+Returns every whitelisted XUID and name, sorted.
+*/
+func (m *Maintenance) Whitelist() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]string, 0, len(m.whitelist))
+	for entry := range m.whitelist {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+func (m *Maintenance) set(enabled bool) {
+	m.mu.Lock()
+	m.enabled = enabled
+	m.mu.Unlock()
+}
+
+/*
+Enables or disables maintenance, if kick is true every player online that isn't whitelisted is disconnected.
+*/
+func (s *Sun) SetMaintenance(enabled bool, kick bool) {
+	s.Maintenance.set(enabled)
+	if !enabled || !kick {
+		return
+	}
+	for _, ray := range s.Rays.All() {
+		if !s.Maintenance.Allowed(ray.IdentityData()) {
+			s.DisconnectRay(ray, s.Maintenance.Message)
+		}
+	}
+}
+
+/*
+PlanetMaintenance is sent by a planet to enable or disable maintenance, Kick disconnects the players online that
+aren't whitelisted.
+*/
+type PlanetMaintenance struct {
+	Enabled bool
+	Kick    bool
+}
+
+func (pk *PlanetMaintenance) ID() uint32 {
+	return IDPlanetMaintenance
+}
+
+func (pk *PlanetMaintenance) Marshal(w *protocol.Writer) {
+	w.Bool(&pk.Enabled)
+	w.Bool(&pk.Kick)
+}
+
+func (pk *PlanetMaintenance) Unmarshal(r *protocol.Reader) {
+	r.Bool(&pk.Enabled)
+	r.Bool(&pk.Kick)
+}
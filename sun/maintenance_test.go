@@ -0,0 +1,32 @@
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"testing"
+)
+
+func TestMaintenanceAllowed(t *testing.T) {
+	m := NewMaintenance(true, "", "", []string{"123", "Steve"})
+	for _, c := range []struct {
+		identity   login.IdentityData
+		matchNames bool
+		allowed    bool
+	}{
+		{login.IdentityData{XUID: "123", DisplayName: "Alex"}, false, true},
+		{login.IdentityData{XUID: "456", DisplayName: "Steve"}, false, false},
+		{login.IdentityData{DisplayName: "steve"}, false, false},
+		{login.IdentityData{XUID: "456", DisplayName: "Steve"}, true, true},
+		{login.IdentityData{XUID: "456", DisplayName: "Alex"}, true, false},
+		{login.IdentityData{DisplayName: "Alex"}, true, false},
+	} {
+		m.MatchNames = c.matchNames
+		if m.Allowed(c.identity) != c.allowed {
+			t.Errorf("expected Allowed(%v, %v) with MatchNames %v to be %v", c.identity.XUID, c.identity.DisplayName, c.matchNames, c.allowed)
+		}
+	}
+
+	m.set(false)
+	if !m.Allowed(login.IdentityData{DisplayName: "Alex"}) {
+		t.Fatal("expected everyone to be allowed without maintenance")
+	}
+}
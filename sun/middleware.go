@@ -0,0 +1,146 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+/*
+Direction is the way a packet travels through the proxy.
+*/
+type Direction uint8
+
+const (
+	//Serverbound packets are sent by the player to their server.
+	Serverbound Direction = iota
+	//Clientbound packets are sent by the server to the player.
+	Clientbound
+)
+
+/*
+PacketContext is passed to a PacketHandler along with the packet.
+*/
+type PacketContext struct {
+	Ray       *Ray
+	Direction Direction
+}
+
+/*
+Sends a packet to the player. The entity IDs in it are translated like those of the packets of the server, so they
+are the IDs the server of the player uses.
+*/
+func (ctx *PacketContext) SendToClient(pk packet.Packet) error {
+	ctx.Ray.translatePacket(pk)
+	return ctx.Ray.conn.WritePacket(pk)
+}
+
+/*
+Sends a packet to the server of the player. The entity IDs in it are translated like those of the packets of the
+player, so they are the IDs the player uses.
+*/
+func (ctx *PacketContext) SendToServer(pk packet.Packet) error {
+	ctx.Ray.translatePacket(pk)
+	return ctx.Ray.Remote().conn.WritePacket(pk)
+}
+
+/*
+Sends a packet back to where the packet being handled came from.
+*/
+func (ctx *PacketContext) Reply(pk packet.Packet) error {
+	if ctx.Direction == Serverbound {
+		return ctx.SendToClient(pk)
+	}
+	return ctx.SendToServer(pk)
+}
+
+/*
+PacketHandler handles a packet passing through the proxy. It returns the packet that should be passed on, which may
+be the packet passed after modifying it or a different packet, or nil to drop it.
+*/
+type PacketHandler func(ctx *PacketContext, pk packet.Packet) packet.Packet
+
+/*
+Middleware holds the chains of PacketHandlers packets pass through in both directions. Handlers run after the
+entity IDs in the packet are translated, in the order of their Priority.
+*/
+type Middleware struct {
+	serverbound handlers
+	clientbound handlers
+}
+
+/*
+Returns a new Middleware without handlers.
+*/
+func NewMiddleware() *Middleware {
+	return &Middleware{}
+}
+
+/*
+Adds a handler for the packets sent by players, it returns a function that removes it again. The packets are
+reused by gophertunnel for the next packet of the same type, so a packet has to be copied to keep it or to use it in
+another goroutine.
+*/
+func (m *Middleware) Serverbound(priority Priority, h PacketHandler) func() {
+	return m.serverbound.add(priority, h)
+}
+
+/*
+Adds a handler for the packets sent to players, it returns a function that removes it again. The packets are
+reused by gophertunnel for the next packet of the same type, so a packet has to be copied to keep it or to use it in
+another goroutine.
+*/
+func (m *Middleware) Clientbound(priority Priority, h PacketHandler) func() {
+	return m.clientbound.add(priority, h)
+}
+
+/*
+Passes a packet through the chain of its direction, it returns nil if the packet was dropped.
+*/
+func (m *Middleware) handle(ray *Ray, direction Direction, pk packet.Packet) packet.Packet {
+	chain := &m.serverbound
+	if direction == Clientbound {
+		chain = &m.clientbound
+	}
+	ctx := &PacketContext{Ray: ray, Direction: direction}
+	for _, h := range chain.snapshot() {
+		if pk = h.(PacketHandler)(ctx, pk); pk == nil {
+			return nil
+		}
+	}
+	return pk
+}
@@ -0,0 +1,34 @@
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := NewMiddleware()
+	m.Serverbound(PriorityLow, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		if text, ok := pk.(*packet.Text); ok {
+			text.Message = "censored"
+		}
+		return pk
+	})
+	m.Serverbound(PriorityHigh, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		if _, ok := pk.(*packet.Animate); ok {
+			return nil
+		}
+		return pk
+	})
+	m.Clientbound(PriorityNormal, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		t.Fatal("expected the clientbound chain not to run")
+		return pk
+	})
+
+	pk := m.handle(nil, Serverbound, &packet.Text{Message: "bad word"})
+	if text, ok := pk.(*packet.Text); !ok || text.Message != "censored" {
+		t.Fatalf("expected the message to be modified, got %#v", pk)
+	}
+	if pk := m.handle(nil, Serverbound, &packet.Animate{}); pk != nil {
+		t.Fatalf("expected the packet to be dropped, got %#v", pk)
+	}
+}
@@ -0,0 +1,153 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+MOTD holds the server name templates shown in the server list. Templates use the text.Colourf markup and the
+placeholders {online}, {max}, {servers_up} and {version}. If there is more than one template they rotate every
+Interval, a planet may override them at runtime.
+*/
+type MOTD struct {
+	Interval time.Duration
+
+	mu        sync.RWMutex
+	templates []string
+	override  string
+	start     time.Time
+}
+
+/*
+MOTDValues are the values the placeholders of a MOTD template are replaced with.
+*/
+type MOTDValues struct {
+	Online    int
+	Max       int
+	ServersUp int
+	Version   string
+}
+
+/*
+Returns a new MOTD rotating through the templates passed every interval.
+*/
+func NewMOTD(templates []string, interval time.Duration) *MOTD {
+	return &MOTD{Interval: interval, templates: templates, start: time.Now()}
+}
+
+/*
+Replaces the templates that are rotated through.
+*/
+func (m *MOTD) SetTemplates(templates []string) {
+	m.mu.Lock()
+	m.templates = templates
+	m.start = time.Now()
+	m.mu.Unlock()
+}
+
+/*
+Shows the template passed instead of the rotating ones until it is cleared by passing an empty string.
+*/
+func (m *MOTD) SetOverride(template string) {
+	m.mu.Lock()
+	m.override = template
+	m.mu.Unlock()
+}
+
+/*
+Returns the template that should currently be shown.
+*/
+func (m *MOTD) Template() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.override != "" {
+		return m.override
+	}
+	if len(m.templates) == 0 {
+		return ""
+	}
+	if m.Interval <= 0 {
+		return m.templates[0]
+	}
+	return m.templates[int(time.Since(m.start)/m.Interval)%len(m.templates)]
+}
+
+/*
+Returns the current template with its placeholders replaced and its markup coloured.
+*/
+func (m *MOTD) Render(values MOTDValues) string {
+	return RenderMOTD(m.Template(), values)
+}
+
+/*
+Replaces the placeholders in the template passed and colours its markup with text.Colourf.
+*/
+func RenderMOTD(template string, values MOTDValues) string {
+	r := strings.NewReplacer(
+		"{online}", strconv.Itoa(values.Online),
+		"{max}", strconv.Itoa(values.Max),
+		"{servers_up}", strconv.Itoa(values.ServersUp),
+		"{version}", values.Version,
+	)
+	return text.Colourf("%s", r.Replace(template))
+}
+
+/*
+PlanetSetMOTD is sent by a planet to show a MOTD instead of the configured ones, an empty MOTD restores them.
+*/
+type PlanetSetMOTD struct {
+	MOTD string
+}
+
+func (pk *PlanetSetMOTD) ID() uint32 {
+	return IDPlanetSetMOTD
+}
+
+func (pk *PlanetSetMOTD) Marshal(w *protocol.Writer) {
+	w.String(&pk.MOTD)
+}
+
+func (pk *PlanetSetMOTD) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.MOTD)
+}
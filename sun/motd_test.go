@@ -0,0 +1,24 @@
+package sun
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMOTD(t *testing.T) {
+	m := NewMOTD([]string{"<yellow>{online}/{max}</yellow> {servers_up} up", "second"}, time.Hour)
+	got := m.Render(MOTDValues{Online: 3, Max: 50, ServersUp: 2, Version: "1.16.200"})
+	if !strings.Contains(got, "3/50") || !strings.Contains(got, "2 up") || !strings.Contains(got, "§e") {
+		t.Fatalf("unexpected MOTD %q", got)
+	}
+	m.SetOverride("100% {version}")
+	if got := m.Render(MOTDValues{Version: "1.16.200"}); !strings.Contains(got, "100% 1.16.200") {
+		t.Fatalf("expected the override to be shown, got %q", got)
+	}
+	m.SetOverride("")
+	m.start = time.Now().Add(-90 * time.Minute)
+	if m.Template() != "second" {
+		t.Fatalf("expected the MOTD to rotate, got %q", m.Template())
+	}
+}
@@ -0,0 +1,157 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"strings"
+	"sync"
+)
+
+/*
+PlayerPermissions are the permissions granted to the player with the XUID or name in Player.
+*/
+type PlayerPermissions struct {
+	Player      string
+	Permissions []string
+}
+
+/*
+Permissions keeps track of the permissions of players. A permission ending in * grants every permission starting
+with what comes before it, so sun.command.* grants every proxy command and * grants everything.
+*/
+type Permissions struct {
+	//MatchNames grants players the permissions set for their name, see matchesPlayer.
+	MatchNames bool
+
+	mu       sync.RWMutex
+	defaults map[string]bool
+	players  map[string]map[string]bool
+}
+
+/*
+Returns new Permissions granting everyone the defaults passed, and the players passed their own permissions.
+*/
+func NewPermissions(defaults []string, players []PlayerPermissions) *Permissions {
+	p := &Permissions{defaults: make(map[string]bool), players: make(map[string]map[string]bool)}
+	for _, permission := range defaults {
+		p.defaults[strings.ToLower(permission)] = true
+	}
+	for _, player := range players {
+		for _, permission := range player.Permissions {
+			p.Set(player.Player, permission, true)
+		}
+	}
+	return p
+}
+
+/*
+Grants or revokes a permission of the player with the XUID or name passed.
+*/
+func (p *Permissions) Set(player, permission string, granted bool) {
+	player, permission = strings.ToLower(player), strings.ToLower(permission)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !granted {
+		delete(p.players[player], permission)
+		if len(p.players[player]) == 0 {
+			delete(p.players, player)
+		}
+		return
+	}
+	if p.players[player] == nil {
+		p.players[player] = make(map[string]bool)
+	}
+	p.players[player][permission] = true
+}
+
+/*
+Returns true if the player with the identity passed has the permission, an empty permission is always granted.
+*/
+func (p *Permissions) Has(identity login.IdentityData, permission string) bool {
+	if permission == "" {
+		return true
+	}
+	permission = strings.ToLower(permission)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if grants(p.defaults, permission) || (identity.XUID != "" && grants(p.players[identity.XUID], permission)) {
+		return true
+	}
+	return p.MatchNames && grants(p.players[strings.ToLower(identity.DisplayName)], permission)
+}
+
+/*
+Returns true if the permission is in granted or matched by one of its wildcards.
+*/
+func grants(granted map[string]bool, permission string) bool {
+	if granted[permission] || granted["*"] {
+		return true
+	}
+	for i := strings.LastIndex(permission, "."); i > 0; i = strings.LastIndex(permission[:i], ".") {
+		if granted[permission[:i]+".*"] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+PlanetPermission is sent by a planet to grant or revoke a permission of the player with the XUID or name in Player.
+*/
+type PlanetPermission struct {
+	Player     string
+	Permission string
+	Granted    bool
+}
+
+func (pk *PlanetPermission) ID() uint32 {
+	return IDPlanetPermission
+}
+
+func (pk *PlanetPermission) Marshal(w *protocol.Writer) {
+	w.String(&pk.Player)
+	w.String(&pk.Permission)
+	w.Bool(&pk.Granted)
+}
+
+func (pk *PlanetPermission) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Player)
+	r.String(&pk.Permission)
+	r.Bool(&pk.Granted)
+}
@@ -0,0 +1,33 @@
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	p := NewPermissions([]string{"sun.command.hub"}, []PlayerPermissions{{Player: "Steve", Permissions: []string{"sun.command.*"}}})
+	steve := login.IdentityData{XUID: "1", DisplayName: "Steve"}
+	alex := login.IdentityData{XUID: "2", DisplayName: "Alex"}
+	if !p.Has(alex, "sun.command.hub") || !p.Has(alex, "") {
+		t.Fatal("expected the default permissions to be granted to everyone")
+	}
+	if p.Has(alex, "sun.command.find") {
+		t.Fatal("expected alex not to have sun.command.find")
+	}
+	if p.Has(steve, "sun.command.find") {
+		t.Fatal("expected the permissions of a name not to be granted without MatchNames")
+	}
+	p.MatchNames = true
+	if !p.Has(steve, "sun.command.find") {
+		t.Fatal("expected the wildcard to grant steve sun.command.find")
+	}
+	p.Set("2", "*", true)
+	if !p.Has(alex, "anything.at.all") {
+		t.Fatal("expected * to grant everything")
+	}
+	p.Set("2", "*", false)
+	if p.Has(alex, "anything.at.all") {
+		t.Fatal("expected the permission to be revoked")
+	}
+}
@@ -32,20 +32,77 @@ SOFTWARE.
 package sun
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
+/*
+The maximum size of the payload of a single packet sent over the tcp protocol.
+*/
+const MaxPlanetPacketSize = 1 << 20
+
+/*
+ErrUnknownPacket is returned by ReadPacket for a packet id it doesn't know, for example one sent by a newer planet. The
+payload of the packet is skipped so the connection can still be read from.
+*/
+var ErrUnknownPacket = errors.New("unknown packet id")
+
+/*
+planetPackets holds a function returning a fresh packet for every packet id of the tcp protocol.
+*/
+var planetPackets = map[uint32]func() packet.Packet{
+	IDPlanetAuth:                   func() packet.Packet { return &PlanetAuth{} },
+	IDPlanetDisconnect:             func() packet.Packet { return &PlanetDisconnect{} },
+	IDPlanetTransfer:               func() packet.Packet { return &PlanetTransfer{} },
+	IDPlanetTransferResponse:       func() packet.Packet { return &PlanetTransferResponse{} },
+	IDPlanetText:                   func() packet.Packet { return &PlanetText{} },
+	IDPlanetTextResponse:           func() packet.Packet { return &PlanetTextResponse{} },
+	IDPlanetRegisterServer:         func() packet.Packet { return &PlanetRegisterServer{} },
+	IDPlanetUnregisterServer:       func() packet.Packet { return &PlanetUnregisterServer{} },
+	IDPlanetServerStatusRequest:    func() packet.Packet { return &PlanetServerStatusRequest{} },
+	IDPlanetServerStatusResponse:   func() packet.Packet { return &PlanetServerStatusResponse{} },
+	IDPlanetPlayerCount:            func() packet.Packet { return &PlanetPlayerCount{} },
+	IDPlanetSetMOTD:                func() packet.Packet { return &PlanetSetMOTD{} },
+	IDPlanetMaintenance:            func() packet.Packet { return &PlanetMaintenance{} },
+	IDPlanetBan:                    func() packet.Packet { return &PlanetBan{} },
+	IDPlanetUnban:                  func() packet.Packet { return &PlanetUnban{} },
+	IDPlanetWhitelist:              func() packet.Packet { return &PlanetWhitelist{} },
+	IDPlanetPermission:             func() packet.Packet { return &PlanetPermission{} },
+	IDPlanetMute:                   func() packet.Packet { return &PlanetMute{} },
+	IDPlanetUnmute:                 func() packet.Packet { return &PlanetUnmute{} },
+	IDPlanetChannel:                func() packet.Packet { return &PlanetChannel{} },
+	IDPlanetChannelMember:          func() packet.Packet { return &PlanetChannelMember{} },
+	IDPlanetTitle:                  func() packet.Packet { return &PlanetTitle{} },
+	IDPlanetBossBar:                func() packet.Packet { return &PlanetBossBar{} },
+	IDPlanetSound:                  func() packet.Packet { return &PlanetSound{} },
+	IDPlanetAuthResponse:           func() packet.Packet { return &PlanetAuthResponse{} },
+	IDPlanetRegisterServerResponse: func() packet.Packet { return &PlanetRegisterServerResponse{} },
+	IDPlanetPeerCountRequest:       func() packet.Packet { return &PlanetPeerCountRequest{} },
+	IDPlanetPeerCountResponse:      func() packet.Packet { return &PlanetPeerCountResponse{} },
+}
+
 type Planet struct {
-	buf bytes.Buffer
-	pool packet.Pool
-	conn net.Conn
-	id uuid.UUID
+	buf     bytes.Buffer
+	writeMu sync.Mutex
+	reader  *bufio.Reader
+	conn    net.Conn
+	id      uuid.UUID
+	//servers holds the servers registered by the planet, by name
+	servers   map[string]Server
+	serversMu sync.Mutex
 }
 
 func NewPlanet(ip IpAddr) (*Planet, error) {
@@ -56,67 +113,293 @@ func NewPlanet(ip IpAddr) (*Planet, error) {
 	return &Planet{conn: conn}, nil
 }
 
-func (p *Planet) ReadPacket() (packet.Packet, error) {
+/*
+Returns a Planet reading and writing packets over a connection that is already open.
+*/
+func NewPlanetConn(conn net.Conn) *Planet {
+	return &Planet{conn: conn}
+}
+
+/*
+Returns the id the planet was given when it was added to the proxy.
+*/
+func (p *Planet) ID() uuid.UUID {
+	return p.id
+}
+
+/*
+Closes the connection to the planet.
+*/
+func (p *Planet) Close() error {
+	return p.conn.Close()
+}
+
+/*
+Reads a single packet from the planet, every packet is framed as a little endian uint32 length of the payload,
+a little endian uint32 packet id and then the payload itself.
+*/
+func (p *Planet) ReadPacket() (pk packet.Packet, err error) {
+	if p.reader == nil {
+		p.reader = bufio.NewReader(p.conn)
+	}
 	var length uint32
-	err := binary.Read(p.conn, binary.LittleEndian, &length)
-	if err != nil {
-		log.Printf("Invalid packet recieved length not sent from planet: %s!\n", p.conn.RemoteAddr())
+	if err := binary.Read(p.reader, binary.LittleEndian, &length); err != nil {
 		return nil, err
 	}
 	var id uint32
-	err = binary.Read(p.conn, binary.LittleEndian, &id)
-	if err != nil {
-		log.Printf("Invalid packet recieved didn't send correct packet formated from planet: %s!\n", p.conn.RemoteAddr())
+	if err := binary.Read(p.reader, binary.LittleEndian, &id); err != nil {
+		return nil, err
+	}
+	if length > MaxPlanetPacketSize {
+		return nil, fmt.Errorf("packet %v from planet %s is %v bytes long which exceeds the maximum of %v", id, p.conn.RemoteAddr(), length, MaxPlanetPacketSize)
+	}
+	f, ok := planetPackets[id]
+	if !ok {
+		//read past the payload so the next packet can still be read
+		if _, err := io.CopyN(ioutil.Discard, p.reader, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w %v received from planet %s", ErrUnknownPacket, id, p.conn.RemoteAddr())
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
 		return nil, err
 	}
-	return p.pool[id], err
+	defer func() {
+		if recoveredErr := recover(); recoveredErr != nil {
+			pk = nil
+			err = fmt.Errorf("error decoding packet %v from planet %s: %v", id, p.conn.RemoteAddr(), recoveredErr)
+		}
+	}()
+	pk = f()
+	pk.Unmarshal(protocol.NewReader(bytes.NewBuffer(payload), 0))
+	return pk, nil
 }
 
+/*
+Writes a single packet to the planet using the same framing ReadPacket expects.
+*/
 func (p *Planet) WritePacket(pk packet.Packet) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	defer p.buf.Reset()
 	pk.Marshal(protocol.NewWriter(&p.buf, 0))
-	buf := bytes.NewBuffer(make([]byte, 0, 4+len(p.buf.Bytes())))
-	if err := binary.Write(buf, binary.LittleEndian, uint32(len(p.buf.Bytes()))); err != nil {
+	if p.buf.Len() > MaxPlanetPacketSize {
+		return fmt.Errorf("packet %v is %v bytes long which exceeds the maximum of %v", pk.ID(), p.buf.Len(), MaxPlanetPacketSize)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 8+p.buf.Len()))
+	if err := binary.Write(buf, binary.LittleEndian, uint32(p.buf.Len())); err != nil {
+		return err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, pk.ID()); err != nil {
 		return err
 	}
 	if _, err := buf.Write(p.buf.Bytes()); err != nil {
 		return err
 	}
-
 	if _, err := p.conn.Write(buf.Bytes()); err != nil {
 		return err
 	}
-	p.buf.Reset()
 	return nil
 }
 
 func (s *Sun) handlePlanet(planet *Planet) {
+	s.wg.Add(1)
 	go func() {
+		defer s.wg.Done()
 		for {
 			pk, err := planet.ReadPacket()
+			if errors.Is(err, ErrUnknownPacket) {
+				//the planet may be newer than the proxy, so just skip what it doesn't understand
+				log.Println(err)
+				continue
+			}
 			if err != nil {
 				log.Println(err)
+				_ = planet.conn.Close()
+				s.Planets.Remove(planet)
+				s.unregisterServers(planet)
+				s.Counter.RemovePlanet(planet.ID())
 				return
 			}
-			if pk, ok := pk.(*PlanetTransfer); ok {
-				if ray, ok := s.Rays[pk.User]; ok {
-					s.TransferRay(ray, IpAddr{Address: pk.Address, Port: pk.Port})
+			if pk, ok := pk.(*PlanetRegisterServer); ok {
+				err := s.registerServer(planet, pk.Server)
+				if err != nil {
+					log.Printf("Planet %s failed to register server %v: %v\n", planet.conn.RemoteAddr(), pk.Server.Name, err)
 				} else {
-					log.Printf("Received bad request from planet: %s, the player by uuid %s was not found!\n", planet.conn.RemoteAddr(), pk.User)
+					log.Printf("Planet %s registered server %v at %v\n", planet.conn.RemoteAddr(), pk.Server.Name, pk.Server.Address.ToString())
+				}
+				status, msg := planetStatus(err)
+				_ = planet.WritePacket(&PlanetRegisterServerResponse{RequestID: pk.RequestID, Status: status, Error: msg})
+				continue
+			}
+			if pk, ok := pk.(*PlanetBan); ok {
+				ban := Ban{Kind: pk.Kind, Value: pk.Value, Reason: pk.Reason, Source: planet.conn.RemoteAddr().String()}
+				if pk.Duration > 0 {
+					ban.Expires = time.Now().Add(pk.Duration)
+				}
+				if err := s.BanPlayer(ban); err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetUnban); ok {
+				if _, err := s.Bans.Remove(pk.Kind, pk.Value); err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetMute); ok {
+				mute := Mute{Player: pk.Player, Reason: pk.Reason, Source: planet.conn.RemoteAddr().String()}
+				if pk.Duration > 0 {
+					mute.Expires = time.Now().Add(pk.Duration)
+				}
+				if err := s.MutePlayer(mute); err != nil {
+					log.Println(err)
 				}
 				continue
 			}
-			if pk, ok := pk.(*Text); ok {
-				//Only iterate if we have to.
-				if len(pk.Servers) > 0 {
-					//in a new routine because of the iteration
-					go s.SendMessageToServers(pk.Message, pk.Servers)
+			if pk, ok := pk.(*PlanetUnmute); ok {
+				if _, err := s.Bans.Unmute(pk.Player); err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetWhitelist); ok {
+				var err error
+				switch pk.Action {
+				case WhitelistDisable, WhitelistEnable:
+					err = s.SetWhitelist(pk.Action == WhitelistEnable)
+				case WhitelistAdd:
+					err = s.Bans.AddWhitelist(pk.Entry)
+				case WhitelistRemove:
+					err = s.Bans.RemoveWhitelist(pk.Entry)
+				}
+				if err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetChannel); ok {
+				if pk.Remove {
+					s.Channels.Remove(pk.Channel.Name)
+				} else {
+					s.Channels.Add(pk.Channel)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetChannelMember); ok {
+				s.Channels.SetMember(pk.Channel, pk.Player, pk.Member)
+				continue
+			}
+			if pk, ok := pk.(*PlanetTitle); ok {
+				s.SendTitle(&pk.Title)
+				continue
+			}
+			if pk, ok := pk.(*PlanetBossBar); ok {
+				s.SetBossBar(&pk.BossBar)
+				continue
+			}
+			if pk, ok := pk.(*PlanetSound); ok {
+				s.PlaySound(&pk.Sound)
+				continue
+			}
+			if pk, ok := pk.(*PlanetPermission); ok {
+				s.Permissions.Set(pk.Player, pk.Permission, pk.Granted)
+				continue
+			}
+			if pk, ok := pk.(*PlanetMaintenance); ok {
+				s.SetMaintenance(pk.Enabled, pk.Kick)
+				log.Printf("Planet %s set maintenance to %v\n", planet.conn.RemoteAddr(), pk.Enabled)
+				continue
+			}
+			if pk, ok := pk.(*PlanetSetMOTD); ok {
+				s.MOTD.SetOverride(pk.MOTD)
+				continue
+			}
+			if pk, ok := pk.(*PlanetPlayerCount); ok {
+				s.Counter.SetPlanetCount(planet.ID(), int(pk.Count))
+				continue
+			}
+			if pk, ok := pk.(*PlanetServerStatusRequest); ok {
+				_ = planet.WritePacket(&PlanetServerStatusResponse{RequestID: pk.RequestID, Servers: s.ServerStatuses()})
+				continue
+			}
+			if pk, ok := pk.(*PlanetUnregisterServer); ok {
+				planet.serversMu.Lock()
+				server, ok := planet.servers[strings.ToLower(pk.Name)]
+				delete(planet.servers, strings.ToLower(pk.Name))
+				planet.serversMu.Unlock()
+				if ok {
+					s.Servers.RemoveMatching(server)
+					log.Printf("Planet %s unregistered server %v\n", planet.conn.RemoteAddr(), pk.Name)
+				}
+				continue
+			}
+			if pk, ok := pk.(*PlanetTransfer); ok {
+				ray, ok := s.Rays.ByIdentity(pk.User)
+				if !ok {
+					log.Printf("Received bad request from planet: %s, the player by uuid %s was not found!\n", planet.conn.RemoteAddr(), pk.User)
+					_ = planet.WritePacket(&PlanetTransferResponse{RequestID: pk.RequestID, Status: PlanetStatusPlayerNotFound,
+						Error: fmt.Sprintf("the player by uuid %s was not found", pk.User)})
 					continue
 				}
-				//if len(pk.Servers) == 0
-				s.SendMessage(pk.Message)
+				//in a new routine so the planet can keep sending requests while the player spawns
+				s.wg.Add(1)
+				go func(pk *PlanetTransfer) {
+					defer s.wg.Done()
+					status, msg := planetStatus(s.TransferRay(ray, IpAddr{Address: pk.Address, Port: pk.Port}))
+					_ = planet.WritePacket(&PlanetTransferResponse{RequestID: pk.RequestID, Status: status, Error: msg})
+				}(pk)
+				continue
+			}
+			if pk, ok := pk.(*PlanetText); ok {
+				//in a new routine because of the iteration
+				s.wg.Add(1)
+				go func(pk *PlanetText) {
+					defer s.wg.Done()
+					s.Broadcast(&pk.Text)
+					_ = planet.WritePacket(&PlanetTextResponse{RequestID: pk.RequestID, Status: PlanetStatusOK})
+				}(pk)
 				continue
 			}
 		}
 	}()
 }
 
+/*
+Adds a server registered by a planet, servers without a name or port and servers with the name of a server in the
+config are rejected with a *TransferError.
+*/
+func (s *Sun) registerServer(planet *Planet, server Server) error {
+	if strings.TrimSpace(server.Name) == "" {
+		return &TransferError{Status: PlanetStatusInvalidServer, Err: errors.New("the server has no name")}
+	}
+	if server.Address.Port == 0 {
+		//port 0 means a server is referred to by name
+		return &TransferError{Status: PlanetStatusInvalidServer, Err: fmt.Errorf("the server %v has no port", server.Name)}
+	}
+	if s.configServer(server.Name) {
+		return &TransferError{Status: PlanetStatusServerExists, Err: fmt.Errorf("the server %v is set in the config of the proxy", server.Name)}
+	}
+	s.Servers.Add(server)
+	planet.serversMu.Lock()
+	if planet.servers == nil {
+		planet.servers = make(map[string]Server)
+	}
+	planet.servers[strings.ToLower(server.Name)] = server
+	planet.serversMu.Unlock()
+	return nil
+}
+
+/*
+Removes every server the planet registered, unless another planet registered a server by the same name since.
+*/
+func (s *Sun) unregisterServers(planet *Planet) {
+	planet.serversMu.Lock()
+	defer planet.serversMu.Unlock()
+	for name, server := range planet.servers {
+		s.Servers.RemoveMatching(server)
+		delete(planet.servers, name)
+	}
+}
@@ -0,0 +1,89 @@
+package sun
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPlanetPacketRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	writer, reader := &Planet{conn: client}, &Planet{conn: server}
+
+	go func() {
+		_ = writer.WritePacket(&PlanetTransfer{Address: "127.0.0.1", Port: 19133, User: "Steve"})
+		_ = writer.WritePacket(&PlanetText{Text: Text{Servers: []string{"127.0.0.1:19133"}, Message: "Hello", Type: TextTip}})
+	}()
+	pk, err := reader.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer, ok := pk.(*PlanetTransfer)
+	if !ok || transfer.Address != "127.0.0.1" || transfer.Port != 19133 || transfer.User != "Steve" {
+		t.Fatalf("unexpected packet %#v", pk)
+	}
+	pk, err = reader.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt, ok := pk.(*PlanetText); !ok || len(txt.Servers) != 1 || txt.Servers[0] != "127.0.0.1:19133" || txt.Message != "Hello" || txt.Type != TextTip {
+		t.Fatalf("unexpected packet %#v", pk)
+	}
+}
+
+func TestPlanetPacketRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	reader := &Planet{conn: server}
+
+	go func() {
+		//unknown id with a short payload, followed by a frame that is too large
+		_ = binary.Write(client, binary.LittleEndian, []uint32{2, 0xFFFF})
+		_, _ = client.Write([]byte{1, 2})
+		_ = binary.Write(client, binary.LittleEndian, []uint32{MaxPlanetPacketSize + 1, IDPlanetAuth})
+	}()
+	if pk, err := reader.ReadPacket(); !errors.Is(err, ErrUnknownPacket) {
+		t.Fatalf("expected ErrUnknownPacket for an unknown id, got %#v, %v", pk, err)
+	}
+	if pk, err := reader.ReadPacket(); err == nil || errors.Is(err, ErrUnknownPacket) {
+		t.Fatalf("expected an error for an oversized frame, got %#v, %v", pk, err)
+	}
+}
+
+func TestRegisterServer(t *testing.T) {
+	hub := Server{Name: "Hub", Address: IpAddr{Address: "127.0.0.1", Port: 19133}}
+	s := &Sun{Servers: NewServerRegistry([]Server{hub}), config: Config{Servers: []Server{hub}}}
+	planet := &Planet{}
+
+	game := Server{Name: "game", Address: IpAddr{Address: "127.0.0.1", Port: 19134}}
+	if err := s.registerServer(planet, game); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Servers.ByName("game"); !ok {
+		t.Fatal("expected the server to be registered")
+	}
+	for _, server := range []Server{
+		{Name: " ", Address: IpAddr{Address: "127.0.0.1", Port: 19135}},
+		{Name: "nope", Address: IpAddr{Address: "127.0.0.1"}},
+		{Name: "hub", Address: IpAddr{Address: "10.0.0.1", Port: 19132}},
+	} {
+		if status, _ := planetStatus(s.registerServer(planet, server)); status == PlanetStatusOK {
+			t.Fatalf("expected %#v to be rejected", server)
+		}
+	}
+	if server, _ := s.Servers.ByName("hub"); server.Address != hub.Address {
+		t.Fatal("expected the config server to be left alone")
+	}
+
+	s.unregisterServers(planet)
+	if _, ok := s.Servers.ByName("game"); ok {
+		t.Fatal("expected the server of the planet to be removed")
+	}
+	if _, ok := s.Servers.ByName("hub"); !ok {
+		t.Fatal("expected the config server to be kept")
+	}
+}
@@ -0,0 +1,291 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+PlayerCommand is a command players run from chat that is handled by the proxy instead of their server.
+*/
+type PlayerCommand struct {
+	//Name must be lower case, the client doesn't accept upper case command names.
+	Name        string
+	Aliases     []string
+	Description string
+	//Permission is required to see and run the command, everyone can run it if it is empty.
+	Permission string
+	//Parameters are shown to the client so it can autocomplete the command.
+	Parameters []protocol.CommandParameter
+	//Run runs the command for the player with the arguments after its name. An error is shown to the player.
+	//It runs in a new routine, so it may block without holding up the packets of the player, such as for a transfer.
+	Run func(ray *Ray, args []string) error
+}
+
+/*
+PlayerCommands holds the commands players can run on the proxy.
+*/
+type PlayerCommands struct {
+	mu       sync.RWMutex
+	commands map[string]*PlayerCommand
+}
+
+/*
+Returns a new empty PlayerCommands.
+*/
+func NewPlayerCommands() *PlayerCommands {
+	return &PlayerCommands{commands: make(map[string]*PlayerCommand)}
+}
+
+/*
+Registers a command, it fails if the name or one of the aliases is taken.
+*/
+func (c *PlayerCommands) Register(cmd PlayerCommand) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, ok := c.commands[strings.ToLower(name)]; ok {
+			return fmt.Errorf("command %v is already registered", name)
+		}
+	}
+	for _, name := range names {
+		c.commands[strings.ToLower(name)] = &cmd
+	}
+	return nil
+}
+
+/*
+Returns the command with the name or alias passed.
+*/
+func (c *PlayerCommands) Command(name string) (*PlayerCommand, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cmd, ok := c.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+/*
+Returns every registered command sorted by name.
+*/
+func (c *PlayerCommands) All() []*PlayerCommand {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var cmds []*PlayerCommand
+	for name, cmd := range c.commands {
+		if name == strings.ToLower(cmd.Name) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+/*
+Runs the command line the player sent if it is a proxy command, it returns false if the line should be sent to
+the server of the player.
+*/
+func (s *Sun) handleCommand(ray *Ray, line string) bool {
+	args := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := s.Commands.Command(args[0])
+	if !ok {
+		return false
+	}
+	if !s.Permissions.Has(ray.IdentityData(), cmd.Permission) {
+		//let the server tell the player the command doesn't exist
+		return false
+	}
+	//in a new routine so the player keeps sending packets while the command runs
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := cmd.Run(ray, args[1:]); err != nil {
+			_ = ray.SendMessage(text.Colourf("<red>%v</red>", err))
+		}
+	}()
+	return true
+}
+
+/*
+Adds the proxy commands the player has permission for to the commands their server sent, replacing server commands
+with the same name.
+*/
+func (s *Sun) injectCommands(ray *Ray, pk *packet.AvailableCommands) {
+	proxy := make(map[string]bool)
+	var commands []protocol.Command
+	for _, cmd := range s.Commands.All() {
+		if !s.Permissions.Has(ray.IdentityData(), cmd.Permission) {
+			continue
+		}
+		proxy[cmd.Name] = true
+		for _, alias := range cmd.Aliases {
+			proxy[alias] = true
+		}
+		commands = append(commands, protocol.Command{
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Aliases:     cmd.Aliases,
+			Overloads:   []protocol.CommandOverload{{Parameters: cmd.Parameters}},
+		})
+	}
+	for _, cmd := range pk.Commands {
+		if !proxy[cmd.Name] {
+			commands = append(commands, cmd)
+		}
+	}
+	pk.Commands = commands
+}
+
+/*
+Registers /server, /hub, /glist and /find, and the middleware that runs them and shows them to the players.
+*/
+func (s *Sun) registerPlayerCommands() {
+	s.Middleware.Serverbound(PriorityNormal, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		if req, ok := pk.(*packet.CommandRequest); ok && s.handleCommand(ctx.Ray, req.CommandLine) {
+			return nil
+		}
+		return pk
+	})
+	s.Middleware.Clientbound(PriorityNormal, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		if cmds, ok := pk.(*packet.AvailableCommands); ok {
+			s.injectCommands(ctx.Ray, cmds)
+		}
+		return pk
+	})
+	serverParam := protocol.CommandParameter{Name: "server", Type: protocol.CommandArgValid | protocol.CommandArgTypeString}
+	playerParam := protocol.CommandParameter{Name: "player", Type: protocol.CommandArgValid | protocol.CommandArgTypeTarget}
+	for _, cmd := range []PlayerCommand{
+		{
+			Name:        "server",
+			Description: "Shows your server or moves you to another one",
+			Permission:  "sun.command.server",
+			Parameters:  []protocol.CommandParameter{{Name: serverParam.Name, Type: serverParam.Type, Optional: true}},
+			Run: func(ray *Ray, args []string) error {
+				if len(args) == 0 {
+					var names []string
+					for _, server := range s.Servers.All() {
+						names = append(names, server.Name)
+					}
+					return ray.SendMessage(text.Colourf("<yellow>You are on %v, servers: %v</yellow>",
+						s.Servers.Name(*ray.Remote().Addr()), strings.Join(names, ", ")))
+				}
+				if server, ok := s.Servers.ByName(args[0]); ok {
+					return s.TransferRay(ray, server.Address)
+				}
+				//any address could be dialed otherwise, including those only the proxy can reach
+				if addr := parseServer(args[0]); addr.Port != 0 && s.Permissions.Has(ray.IdentityData(), "sun.command.server.address") {
+					return s.TransferRay(ray, addr)
+				}
+				return fmt.Errorf("there is no server called %v", args[0])
+			},
+		},
+		{
+			Name:        "hub",
+			Aliases:     []string{"lobby"},
+			Description: "Moves you to a hub",
+			Permission:  "sun.command.hub",
+			Run: func(ray *Ray, args []string) error {
+				if s.Hubs.IsHub(*ray.Remote().Addr()) {
+					return errors.New("you are already on a hub")
+				}
+				var err error
+				for _, addr := range s.Hubs.Order(ray) {
+					if err = s.TransferRay(ray, addr); err == nil {
+						return nil
+					}
+				}
+				return err
+			},
+		},
+		{
+			Name:        "glist",
+			Description: "Lists the players on every server",
+			Permission:  "sun.command.glist",
+			Run: func(ray *Ray, args []string) error {
+				players := make(map[string][]string)
+				for _, r := range s.Rays.All() {
+					server := s.Servers.Name(*r.Remote().Addr())
+					players[server] = append(players[server], r.IdentityData().DisplayName)
+				}
+				servers := make([]string, 0, len(players))
+				for server := range players {
+					servers = append(servers, server)
+				}
+				sort.Strings(servers)
+				lines := []string{text.Colourf("<yellow>%v players are online</yellow>", s.Rays.Len())}
+				for _, server := range servers {
+					sort.Strings(players[server])
+					lines = append(lines, text.Colourf("<gold>%v (%v):</gold> %v", server, len(players[server]),
+						strings.Join(players[server], ", ")))
+				}
+				return ray.SendMessage(strings.Join(lines, "\n"))
+			},
+		},
+		{
+			Name:        "find",
+			Description: "Shows the server a player is on",
+			Permission:  "sun.command.find",
+			Parameters:  []protocol.CommandParameter{playerParam},
+			Run: func(ray *Ray, args []string) error {
+				if len(args) == 0 {
+					return errors.New("usage: /find <player>")
+				}
+				target, ok := s.Rays.ByName(strings.Join(args, " "))
+				if !ok {
+					return fmt.Errorf("%v is not online", strings.Join(args, " "))
+				}
+				return ray.SendMessage(text.Colourf("<yellow>%v is on %v</yellow>", target.IdentityData().DisplayName,
+					s.Servers.Name(*target.Remote().Addr())))
+			},
+		},
+	} {
+		_ = s.Commands.Register(cmd)
+	}
+}
@@ -0,0 +1,335 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"go.uber.org/atomic"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+PlayerCounter works out the player count shown in the server list. Next to the players on this proxy it can use
+the counts the backends respond to pings with, the counts of peer proxies behind the same address or the counts
+planets push.
+*/
+type PlayerCounter struct {
+	//Source is where the online count comes from: proxy, backends, peers or planets.
+	Source string
+	//MaxPolicy is how the max player count is worked out: fixed, sum or online+1.
+	MaxPolicy string
+	//Max is the max player count used by the fixed policy.
+	Max int
+
+	local *atomic.Int64
+	//health is used to count the players on the backends, it may be nil.
+	health *HealthChecker
+	//peers are the planet addresses of the other proxies, they are asked for their players with peerKey.
+	peers   []IpAddr
+	peerKey string
+	servers *ServerRegistry
+
+	mu         sync.RWMutex
+	planets    map[uuid.UUID]int
+	peerCounts map[IpAddr]int
+}
+
+/*
+Returns a new PlayerCounter counting the players on this proxy with local.
+*/
+func NewPlayerCounter(source, maxPolicy string, max int, local *atomic.Int64) (*PlayerCounter, error) {
+	source, maxPolicy = strings.ToLower(source), strings.ToLower(maxPolicy)
+	switch source {
+	case "", "proxy", "backends", "peers", "planets":
+	default:
+		return nil, fmt.Errorf("unknown player count source %v", source)
+	}
+	switch maxPolicy {
+	case "", "fixed", "sum", "online+1":
+	default:
+		return nil, fmt.Errorf("unknown max player policy %v", maxPolicy)
+	}
+	return &PlayerCounter{
+		Source:     source,
+		MaxPolicy:  maxPolicy,
+		Max:        max,
+		local:      local,
+		planets:    make(map[uuid.UUID]int),
+		peerCounts: make(map[IpAddr]int),
+	}, nil
+}
+
+/*
+Returns the online and max player count.
+*/
+func (c *PlayerCounter) Count() (online, max int) {
+	online = c.Online()
+	switch c.MaxPolicy {
+	case "sum":
+		max = c.backendMax()
+	case "online+1":
+		max = online + 1
+	default:
+		max = c.Max
+	}
+	return online, max
+}
+
+/*
+Returns the online player count from the Source. The players on this proxy are always counted, sources that
+already include them never report less than that.
+*/
+func (c *PlayerCounter) Online() int {
+	local := int(c.local.Load())
+	switch c.Source {
+	case "backends":
+		if c.health == nil {
+			return local
+		}
+		online := 0
+		for _, health := range c.health.All() {
+			if health.Up {
+				online += health.PlayerCount
+			}
+		}
+		return maxInt(local, online)
+	case "peers":
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		online := local
+		for _, count := range c.peerCounts {
+			online += count
+		}
+		return online
+	case "planets":
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		online := 0
+		for _, count := range c.planets {
+			online += count
+		}
+		return maxInt(local, online)
+	}
+	return local
+}
+
+/*
+Returns the sum of the max players of every backend that is up, from its pings or from its Server config.
+Falls back to Max if none of them is known.
+*/
+func (c *PlayerCounter) backendMax() int {
+	max := 0
+	var health map[IpAddr]ServerHealth
+	if c.health != nil {
+		health = c.health.All()
+	}
+	for _, server := range c.servers.All() {
+		if h, ok := health[server.Address]; ok {
+			if h.Up {
+				max += h.MaxPlayers
+			}
+			continue
+		}
+		max += server.MaxPlayers
+	}
+	if max == 0 {
+		return c.Max
+	}
+	return max
+}
+
+/*
+Returns how many backends are up, every backend counts as up if health checking is disabled.
+*/
+func (c *PlayerCounter) ServersUp() int {
+	up := 0
+	for _, server := range c.servers.All() {
+		if c.health == nil || c.health.Up(server.Address) {
+			up++
+		}
+	}
+	return up
+}
+
+/*
+Sets the player count pushed by the planet with the id passed.
+*/
+func (c *PlayerCounter) SetPlanetCount(id uuid.UUID, count int) {
+	c.mu.Lock()
+	c.planets[id] = count
+	c.mu.Unlock()
+}
+
+/*
+Forgets the player count pushed by the planet with the id passed.
+*/
+func (c *PlayerCounter) RemovePlanet(id uuid.UUID) {
+	c.mu.Lock()
+	delete(c.planets, id)
+	c.mu.Unlock()
+}
+
+/*
+Asks every peer how many players are on it each interval until close is closed. Peers that don't respond are left
+out of the count until they do again.
+*/
+func (c *PlayerCounter) runPeers(interval, timeout time.Duration, close <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		wg.Add(len(c.peers))
+		for _, addr := range c.peers {
+			go func(addr IpAddr) {
+				defer wg.Done()
+				count, err := peerCount(addr, c.peerKey, timeout)
+				c.mu.Lock()
+				if err != nil {
+					delete(c.peerCounts, addr)
+				} else {
+					c.peerCounts[addr] = count
+				}
+				c.mu.Unlock()
+			}(addr)
+		}
+		wg.Wait()
+		select {
+		case <-close:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Asks the proxy with the planet address passed how many players are on it. Only the players on the proxy itself are
+returned, the count in its server list would include this proxy again and grow with every ping.
+*/
+func peerCount(addr IpAddr, key string, timeout time.Duration) (int, error) {
+	conn, err := net.DialTimeout("tcp", addr.ToString(), timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	peer := NewPlanetConn(conn)
+	if err := peer.WritePacket(&PlanetPeerCountRequest{Key: key}); err != nil {
+		return 0, err
+	}
+	pk, err := peer.ReadPacket()
+	if err != nil {
+		return 0, err
+	}
+	switch pk := pk.(type) {
+	case *PlanetPeerCountResponse:
+		return int(pk.Count), nil
+	case *PlanetDisconnect:
+		return 0, fmt.Errorf("peer %v refused the request: %v", addr.ToString(), pk.Message)
+	}
+	return 0, fmt.Errorf("unexpected packet %T from peer %v", pk, addr.ToString())
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+/*
+PlanetPlayerCount is sent by a planet to tell the proxy how many players it has, it replaces the previous count
+of that planet and is forgotten when the planet disconnects.
+*/
+type PlanetPlayerCount struct {
+	Count int32
+}
+
+func (pk *PlanetPlayerCount) ID() uint32 {
+	return IDPlanetPlayerCount
+}
+
+func (pk *PlanetPlayerCount) Marshal(w *protocol.Writer) {
+	w.Varint32(&pk.Count)
+}
+
+func (pk *PlanetPlayerCount) Unmarshal(r *protocol.Reader) {
+	r.Varint32(&pk.Count)
+}
+
+/*
+PlanetPeerCountRequest is sent by a proxy to a peer proxy, instead of a PlanetAuth, to ask how many players are on
+it. The peer responds with a PlanetPeerCountResponse and closes the connection.
+*/
+type PlanetPeerCountRequest struct {
+	Key string
+}
+
+func (pk *PlanetPeerCountRequest) ID() uint32 {
+	return IDPlanetPeerCountRequest
+}
+
+func (pk *PlanetPeerCountRequest) Marshal(w *protocol.Writer) {
+	w.String(&pk.Key)
+}
+
+func (pk *PlanetPeerCountRequest) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Key)
+}
+
+/*
+PlanetPeerCountResponse holds the players on the proxy itself, without those of its own peers or planets.
+*/
+type PlanetPeerCountResponse struct {
+	Count int32
+}
+
+func (pk *PlanetPeerCountResponse) ID() uint32 {
+	return IDPlanetPeerCountResponse
+}
+
+func (pk *PlanetPeerCountResponse) Marshal(w *protocol.Writer) {
+	w.Varint32(&pk.Count)
+}
+
+func (pk *PlanetPeerCountResponse) Unmarshal(r *protocol.Reader) {
+	r.Varint32(&pk.Count)
+}
@@ -0,0 +1,81 @@
+package sun
+
+import (
+	"github.com/google/uuid"
+	"go.uber.org/atomic"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPlayerCounter(t *testing.T) {
+	local := atomic.NewInt64(3)
+	c, err := NewPlayerCounter("planets", "online+1", 50, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.servers = NewServerRegistry(nil)
+	if online, max := c.Count(); online != 3 || max != 4 {
+		t.Fatalf("expected 3/4 without planets, got %v/%v", online, max)
+	}
+	a, b := uuid.New(), uuid.New()
+	c.SetPlanetCount(a, 10)
+	c.SetPlanetCount(b, 5)
+	if online, _ := c.Count(); online != 15 {
+		t.Fatalf("expected 15 online, got %v", online)
+	}
+	c.RemovePlanet(a)
+	if online, _ := c.Count(); online != 5 {
+		t.Fatalf("expected 5 online after removing a planet, got %v", online)
+	}
+
+	c.MaxPolicy = "sum"
+	c.servers = NewServerRegistry([]Server{{Name: "a", MaxPlayers: 20}, {Name: "b", MaxPlayers: 30}})
+	if _, max := c.Count(); max != 50 {
+		t.Fatalf("expected the backends to sum to 50, got %v", max)
+	}
+
+	if _, err := NewPlayerCounter("nowhere", "fixed", 0, local); err == nil {
+		t.Fatal("expected an unknown source to fail")
+	}
+}
+
+func TestPlayerCounterPeers(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	peer := &Sun{Key: "key", Events: NewEvents(), PCooldowns: make(map[string]time.Time), PWarnings: make(map[string]int)}
+	peer.Counter, _ = NewPlayerCounter("peers", "fixed", 0, atomic.NewInt64(4))
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go peer.authPlanet(&Planet{conn: conn})
+		}
+	}()
+	addr := IpAddr{Address: "127.0.0.1", Port: uint16(l.Addr().(*net.TCPAddr).Port)}
+
+	c, _ := NewPlayerCounter("peers", "fixed", 0, atomic.NewInt64(3))
+	c.peers = []IpAddr{addr}
+	c.peerKey = "key"
+	//the peer counts this proxy too, which must not come back
+	peer.Counter.peerCounts[IpAddr{Address: "127.0.0.1", Port: 1}] = 3
+	closed := make(chan struct{})
+	close(closed)
+	for i := 0; i < 2; i++ {
+		c.runPeers(time.Second, time.Second, closed)
+		if online := c.Online(); online != 7 {
+			t.Fatalf("expected 7 online, got %v", online)
+		}
+	}
+
+	c.peerKey = "wrong"
+	c.runPeers(time.Second, time.Second, closed)
+	if online := c.Online(); online != 3 {
+		t.Fatalf("expected a peer that refused to be left out, got %v online", online)
+	}
+}
@@ -37,21 +37,48 @@ SOFTWARE.
 package sun
 
 import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/sandertv/gophertunnel/minecraft"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"github.com/sunproxy/sun/forward"
 	"log"
+	"net"
+	"strconv"
 	"sync"
 	"time"
 )
 
 type Ray struct {
 	conn         *minecraft.Conn
+	identity     login.IdentityData
 	remote       *Remote
 	bufferConn   *Remote
 	Translations *TranslatorMappings
 	transferring bool
 	remoteMu     sync.Mutex
+	closed       chan struct{}
+	closeOnce    sync.Once
+	//remoteChanged is closed and replaced every time the remote of the player changes
+	remoteChanged chan struct{}
+	position      mgl32.Vec3
+	positionMu    sync.Mutex
+	//bossBar is the boss bar of the proxy shown to the player, nil if there is none
+	bossBar   *BossBar
+	bossBarMu sync.Mutex
+	world     *worldState
+}
+
+/**
+Returns a new Ray for the connection passed, the remote still has to be set.
+*/
+func newRay(conn *minecraft.Conn) *Ray {
+	return &Ray{conn: conn, identity: conn.IdentityData(), closed: make(chan struct{}), remoteChanged: make(chan struct{}),
+		world: newWorldState()}
 }
 
 type TranslatorMappings struct {
@@ -61,6 +88,20 @@ type TranslatorMappings struct {
 	CurrentEntityUniqueID   int64
 }
 
+/**
+Returns the connection of the player to the proxy.
+*/
+func (r *Ray) Conn() *minecraft.Conn {
+	return r.conn
+}
+
+/**
+Returns the identity data the player logged into the proxy with.
+*/
+func (r *Ray) IdentityData() login.IdentityData {
+	return r.identity
+}
+
 /**
 Returns the Remote Connection the player has currently.
 */
@@ -70,80 +111,260 @@ func (r *Ray) Remote() *Remote {
 	return r.remote
 }
 
+/**
+Returns the current remote along with a channel that is closed once it is replaced.
+*/
+func (r *Ray) remoteState() (*Remote, <-chan struct{}) {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	return r.remote, r.remoteChanged
+}
+
+/**
+Replaces the remote of the player, waking up everything waiting on the old one.
+*/
+func (r *Ray) setRemote(remote *Remote) {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	r.replaceRemote(remote)
+}
+
+/**
+Replaces the remote of the player, the lock must be held.
+*/
+func (r *Ray) replaceRemote(remote *Remote) {
+	r.remote = remote
+	if r.remoteChanged != nil {
+		close(r.remoteChanged)
+	}
+	r.remoteChanged = make(chan struct{})
+}
+
 /**
 Returns a bool representing if a player is Transferring.
 */
 func (r *Ray) Transferring() bool {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
 	return r.transferring
 }
 
+/**
+Marks the player as transferring, it returns false if they already were.
+*/
+func (r *Ray) startTransfer() bool {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	if r.transferring {
+		return false
+	}
+	r.transferring = true
+	return true
+}
+
+/**
+Sets the connection the player is swapped to once they finished changing dimension.
+*/
+func (r *Ray) setBufferConn(remote *Remote) {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	r.bufferConn = remote
+}
+
+/**
+Stops the transfer of the player, dropping the buffer conn.
+*/
+func (r *Ray) cancelTransfer() {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	r.transferring = false
+	r.bufferConn = nil
+}
+
+/**
+Takes the buffer conn to swap the player to, it returns nil if the player isn't transferring or the new server hasn't
+spawned them yet. The player is transferring until finishTransfer is called.
+*/
+func (r *Ray) takeBufferConn() *Remote {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	if !r.transferring || r.bufferConn == nil {
+		return nil
+	}
+	bufferC := r.bufferConn
+	r.bufferConn = nil
+	return bufferC
+}
+
+/**
+Ends the transfer of the player by making the buffer conn taken with takeBufferConn their remote.
+*/
+func (r *Ray) finishTransfer(bufferC *Remote) {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+	r.transferring = false
+	r.replaceRemote(bufferC)
+}
+
+/**
+Returns a channel that is closed once the player left the proxy.
+*/
+func (r *Ray) Closed() <-chan struct{} {
+	return r.closed
+}
+
+/**
+Sends a chat message to the player.
+*/
+func (r *Ray) SendMessage(message string) error {
+	return r.conn.WritePacket(&packet.Text{Message: message, TextType: packet.TextTypeRaw})
+}
+
 /**
 BufferConn is the connection used to temp out new conns also named temp conn
 */
 func (r *Ray) BufferConn() *Remote {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
 	return r.bufferConn
 }
 
 func (s *Sun) handleRay(ray *Ray) {
+	s.wg.Add(2)
 	go func() {
+		defer s.wg.Done()
 		for {
 			pk, err := ray.conn.ReadPacket()
 			if err != nil {
+				//the player left so clean up after them
+				s.closeRay(ray)
 				return
 			}
 			ray.translatePacket(pk)
+			if pk = s.Middleware.handle(ray, Serverbound, pk); pk == nil {
+				continue
+			}
 			switch pk := pk.(type) {
 			case *packet.PlayerAction:
-				if pk.ActionType == packet.PlayerActionDimensionChangeDone && ray.Transferring() {
-					ray.transferring = false
-
-					old := ray.Remote().conn
-					bufferC := ray.bufferConn
+				if pk.ActionType != packet.PlayerActionDimensionChangeDone {
+					break
+				}
+				if bufferC := ray.takeBufferConn(); bufferC != nil {
+					previous := ray.Remote()
 
-					pos := bufferC.conn.GameData().PlayerPosition
+					data := bufferC.conn.GameData()
+					dim := data.Dimension
+					if s.TransferMode == TransferLegacy {
+						dim = packet.DimensionOverworld
+					}
 					err = ray.conn.WritePacket(&packet.ChangeDimension{
-						Dimension: packet.DimensionOverworld,
-						Position:  pos,
+						Dimension: dim,
+						Position:  data.PlayerPosition,
 					})
 					if err != nil {
+						_ = bufferC.close()
+						ray.cancelTransfer()
 						continue
 					}
-					_ = old.Close()
-					ray.remoteMu.Lock()
-					ray.remote = bufferC
-					ray.bufferConn = nil
-					ray.updateTranslatorData(ray.remote.conn.GameData())
-					ray.remoteMu.Unlock()
+					//close the old server first so nothing it still sends is left behind by the clean up, the player is
+					//transferring until the swap so its reader waits for the new remote
+					_ = previous.close()
+					ray.world.setDimension(dim)
+					ray.resetWorld()
+					ray.updateTranslatorData(data)
+					ray.setPosition(data.PlayerPosition)
+					if s.TransferMode != TransferLegacy {
+						ray.applyGameData(data)
+					}
+					//the new server only gets to send once the old one is cleaned up after
+					ray.finishTransfer(bufferC)
+					//the dimension change removed the boss bar entity
+					ray.restoreBossBar()
 					log.Println("Successfully completed transfer for player ", ray.conn.IdentityData().DisplayName)
+					s.Events.fireServerConnected(&ServerConnectedEvent{Ray: ray, Server: bufferC.addr, Previous: previous.addr})
 					continue
 				}
 			}
 			err = ray.Remote().conn.WritePacket(pk)
 			if err != nil {
-				return
+				select {
+				case <-ray.closed:
+					return
+				default:
+					//the remote went down, drop the packet while the player is moved to a fallback
+					continue
+				}
 			}
 		}
 	}()
 	go func() {
+		defer s.wg.Done()
 		for {
-			pk, err := ray.Remote().conn.ReadPacket()
+			remote, changed := ray.remoteState()
+			pk, err := remote.conn.ReadPacket()
+			if pk, ok := pk.(*packet.Disconnect); ok {
+				//don't let the backend kick the player off the proxy
+				_ = remote.close()
+				err = fmt.Errorf("%v", pk.Message)
+				if pk.Message == "" {
+					err = fmt.Errorf("disconnected by server")
+				}
+			}
 			if err != nil {
-				continue
+				select {
+				case <-ray.closed:
+					return
+				case <-changed:
+					//the remote was closed because the player was transferred
+					continue
+				default:
+				}
+				if !ray.Transferring() {
+					s.fallbackRay(ray, *remote.Addr(), err)
+				}
+				//wait for the player to be moved away from the dead remote
+				select {
+				case <-ray.closed:
+					return
+				case <-changed:
+					continue
+				case <-time.After(2 * time.Minute):
+				}
+				//the transfer never finished, so there's nowhere left to send the player
+				if bufferC := ray.takeBufferConn(); bufferC != nil {
+					_ = bufferC.close()
+				}
+				ray.cancelTransfer()
+				s.DisconnectRay(ray, text.Colourf("<red>The server you were on went down: %v</red>", err))
+				return
 			}
 			ray.translatePacket(pk)
+			if pk = s.Middleware.handle(ray, Clientbound, pk); pk == nil {
+				continue
+			}
 			if pk, ok := pk.(*Transfer); ok {
-				s.TransferRay(ray, IpAddr{Address: pk.Address, Port: pk.Port})
+				_ = s.TransferRay(ray, IpAddr{Address: pk.Address, Port: pk.Port})
 				continue
 			}
+			//in a new routine because of the iteration, the packets are copied because the connection reuses them
 			if pk, ok := pk.(*Text); ok {
-				//Only iterate if we have to.
-				if len(pk.Servers) > 0 {
-					//in a new routine because of the iteration
-					go s.SendMessageToServers(pk.Message, pk.Servers)
-					continue
-				}
-				//if len(pk.Servers) == 0
-				s.SendMessage(pk.Message)
+				t := *pk
+				go s.Broadcast(&t)
+				continue
+			}
+			if pk, ok := pk.(*Title); ok {
+				title := *pk
+				go s.SendTitle(&title)
+				continue
+			}
+			if pk, ok := pk.(*BossBar); ok {
+				bar := *pk
+				go s.SetBossBar(&bar)
+				continue
+			}
+			if pk, ok := pk.(*Sound); ok {
+				sound := *pk
+				go s.PlaySound(&sound)
 				continue
 			}
 			err = ray.conn.WritePacket(pk)
@@ -155,34 +376,125 @@ func (s *Sun) handleRay(ray *Ray) {
 }
 
 /*
-Changes a players remote and readies the connection
+Returns the dialer used to connect a player to the backend at addr, with the forwarding claim embedded if enabled.
+*/
+func (s *Sun) dialer(ray *Ray, idend login.IdentityData, addr IpAddr) minecraft.Dialer {
+	client := ray.conn.ClientData()
+	if s.IpForwarding {
+		host, port, _ := net.SplitHostPort(ray.conn.RemoteAddr().String())
+		p, _ := strconv.ParseUint(port, 10, 16)
+		forward.Embed(&client, forward.Sign(forward.Data{
+			Address:  host,
+			Port:     uint16(p),
+			XUID:     ray.conn.IdentityData().XUID,
+			Identity: idend.Identity,
+			Target:   addr.ToString(),
+			Issued:   time.Now().Unix(),
+		}, s.ForwardingKey))
+	}
+	return minecraft.Dialer{ClientData: client, IdentityData: idend}
+}
+
+/*
+TransferError is returned by TransferRay when a transfer failed, Status is one of the PlanetStatus constants.
+*/
+type TransferError struct {
+	Status uint8
+	Err    error
+}
+
+func (e *TransferError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransferError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Returns the status and error message to send to a planet for the error passed.
+*/
+func planetStatus(err error) (uint8, string) {
+	if err == nil {
+		return PlanetStatusOK, ""
+	}
+	var terr *TransferError
+	if errors.As(err, &terr) {
+		return terr.Status, terr.Error()
+	}
+	return PlanetStatusDisconnected, err.Error()
+}
+
+/*
+Changes a players remote and readies the connection, the error returned is a *TransferError
 */
-func (s *Sun) TransferRay(ray *Ray, addr IpAddr) {
+func (s *Sun) TransferRay(ray *Ray, addr IpAddr) error {
+	err := s.transferRay(ray, addr)
+	if err != nil {
+		s.Events.fireTransferFailed(&TransferFailedEvent{Ray: ray, Target: addr, Err: err})
+	}
+	return err
+}
+
+func (s *Sun) transferRay(ray *Ray, addr IpAddr) error {
 	log.Println("Transfer request received for ", ray.conn.IdentityData().DisplayName)
-	if ray.transferring {
+	if ray.Transferring() {
 		log.Println("Transfer scrapped because it was already transferring for", ray.conn.IdentityData().DisplayName)
-		return
+		return &TransferError{Status: PlanetStatusAlreadyTransferring, Err: fmt.Errorf("%v is already transferring", ray.conn.IdentityData().DisplayName)}
+	}
+	//Look the server up if it was referred to by name
+	resolved, ok := s.Servers.Resolve(addr)
+	if !ok {
+		return &TransferError{Status: PlanetStatusServerNotFound, Err: fmt.Errorf("there is no server named %v", addr.Address)}
+	}
+	addr = resolved
+	ev := &ServerPreConnectEvent{Ray: ray, Target: addr}
+	s.Events.fireServerPreConnect(ev)
+	if ev.Cancelled {
+		return &TransferError{Status: PlanetStatusCancelled, Err: fmt.Errorf("the transfer to %v was cancelled", s.Servers.Name(addr))}
+	}
+	if ev.Target != addr {
+		if addr, ok = s.Servers.Resolve(ev.Target); !ok {
+			return &TransferError{Status: PlanetStatusServerNotFound, Err: fmt.Errorf("there is no server named %v", ev.Target.Address)}
+		}
+	}
+	if s.Health != nil && !s.Health.Up(addr) {
+		return &TransferError{Status: PlanetStatusServerDown, Err: fmt.Errorf("%v is down", s.Servers.Name(addr))}
+	}
+	if !ray.startTransfer() {
+		return &TransferError{Status: PlanetStatusAlreadyTransferring, Err: fmt.Errorf("%v is already transferring", ray.conn.IdentityData().DisplayName)}
 	}
-	ray.transferring = true
 	//Dial the new server based on the ipaddr
 	idend := ray.conn.IdentityData()
 	//clear the xuid this might be the fix
 	idend.XUID = ""
-	conn, err := minecraft.Dialer{
-		ClientData:   ray.conn.ClientData(),
-		IdentityData: idend}.Dial("raknet", addr.ToString())
+	s.ServerCounts.Inc(addr)
+	conn, err := s.dialer(ray, idend, addr).Dial("raknet", addr.ToString())
 	if err != nil {
 		log.Println("error dialing new server for transfer request for", ray.conn.IdentityData().DisplayName+"\n", err)
-		ray.transferring = false
-		return
+		s.ServerCounts.Dec(addr)
+		s.Hubs.MarkUnhealthy(addr)
+		ray.cancelTransfer()
+		return &TransferError{Status: PlanetStatusDialFailed, Err: fmt.Errorf("error dialing %v: %w", addr.ToString(), err)}
 	}
-	ray.bufferConn = &Remote{conn: conn, addr: addr}
+	buffer := &Remote{conn: conn, addr: addr, counter: s.ServerCounts}
 	//do spawn
-	err = ray.BufferConn().conn.DoSpawnTimeout(time.Minute)
+	err = conn.DoSpawnTimeout(time.Minute)
 	if err != nil {
-		//cleanly close player
-		s.BreakRay(ray)
-		return
+		//the player is still on their old server so just drop the new one
+		_ = buffer.close()
+		ray.cancelTransfer()
+		return &TransferError{Status: PlanetStatusSpawnFailed, Err: fmt.Errorf("error spawning in %v: %w", addr.ToString(), err)}
+	}
+	ray.setBufferConn(buffer)
+	select {
+	case <-ray.closed:
+		//the player left while the new server was dialed, closeRay may have missed the buffer conn
+		if bufferC := ray.takeBufferConn(); bufferC != nil {
+			_ = bufferC.close()
+		}
+		return &TransferError{Status: PlanetStatusDisconnected, Err: fmt.Errorf("%v left the proxy", ray.conn.IdentityData().DisplayName)}
+	default:
 	}
 	err = ray.conn.WritePacket(&packet.SetScoreboardIdentity{
 		ActionType: packet.ScoreboardIdentityActionClear,
@@ -190,28 +502,34 @@ func (s *Sun) TransferRay(ray *Ray, addr IpAddr) {
 	})
 	if err != nil {
 		log.Println("error clearing scoreboard for player", ray.conn.IdentityData().DisplayName+"\n", err)
+		_ = buffer.close()
 		s.BreakRay(ray)
-		return
+		return &TransferError{Status: PlanetStatusDisconnected, Err: err}
+	}
+	//move the player to a dimension they are in neither before nor after the transfer while the new server spawns
+	pos := ray.Position()
+	dim := intermediateDimension(ray.world.dimension(), conn.GameData().Dimension)
+	if s.TransferMode == TransferLegacy {
+		dim = packet.DimensionNether
 	}
 	err = ray.conn.WritePacket(&packet.ChangeDimension{
-		Dimension: packet.DimensionNether,
-		Position:  ray.conn.GameData().PlayerPosition,
+		Dimension: dim,
+		Position:  pos,
 	})
 	if err != nil {
 		log.Println("error sending the dimension change request to the player", ray.conn.IdentityData().DisplayName+"\n", err)
+		_ = buffer.close()
 		s.BreakRay(ray)
-		return
+		return &TransferError{Status: PlanetStatusDisconnected, Err: err}
 	}
 	//Update Chunk Radius for players.
 	_ = ray.conn.WritePacket(&packet.NetworkChunkPublisherUpdate{
-		Position: protocol.BlockPos{int32(ray.conn.GameData().PlayerPosition.X()),
-			int32(ray.conn.GameData().PlayerPosition.Y()),
-			int32(ray.conn.GameData().PlayerPosition.Z())},
-		Radius: 12 >> 4,
+		Position: protocol.BlockPos{int32(pos.X()), int32(pos.Y()), int32(pos.Z())},
+		Radius:   12 >> 4,
 	})
-	//send empty chunk data.
-	chunkX := int32(ray.conn.GameData().PlayerPosition.X()) >> 4
-	chunkZ := int32(ray.conn.GameData().PlayerPosition.Z()) >> 4
+	//send empty chunk data, the client only finishes changing dimension once the chunks around it are loaded.
+	chunkX := int32(pos.X()) >> 4
+	chunkZ := int32(pos.Z()) >> 4
 	for x := int32(-1); x <= 1; x++ {
 		for z := int32(-1); z <= 1; z++ {
 			_ = ray.conn.WritePacket(&packet.LevelChunk{
@@ -222,4 +540,33 @@ func (s *Sun) TransferRay(ray *Ray, addr IpAddr) {
 			})
 		}
 	}
+	return nil
+}
+
+/*
+Moves a player whose remote went down to one of the Hubs or the first of the Fallbacks that accepts them, the player is
+only disconnected if all of them fail.
+*/
+func (s *Sun) fallbackRay(ray *Ray, dead IpAddr, reason error) {
+	log.Printf("Remote %v of player %v went down: %v\n", dead.ToString(), ray.IdentityData().DisplayName, reason)
+	_ = ray.conn.WritePacket(&packet.Text{
+		Message:  text.Colourf("<red>The server you were on went down: %v</red>", reason),
+		TextType: packet.TextTypeRaw,
+	})
+	for _, addr := range append(s.Hubs.Order(ray), s.Fallbacks...) {
+		if addr == dead {
+			continue
+		}
+		err := s.TransferRay(ray, addr)
+		if err == nil {
+			return
+		}
+		select {
+		case <-ray.closed:
+			return
+		default:
+		}
+		log.Printf("Fallback %v failed for player %v: %v\n", addr.ToString(), ray.IdentityData().DisplayName, err)
+	}
+	s.DisconnectRay(ray, text.Colourf("<red>The server you were on went down: %v</red>", reason))
 }
@@ -0,0 +1,144 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import "github.com/sandertv/gophertunnel/minecraft/protocol"
+
+/*
+PlanetRegisterServer is sent by a planet to add a server to the Servers of the proxy. The server is removed again
+when the planet disconnects.
+*/
+type PlanetRegisterServer struct {
+	/*
+		RequestID is sent back in the PlanetRegisterServerResponse for this server
+	*/
+	RequestID uint32
+
+	Server Server
+}
+
+func (pk *PlanetRegisterServer) ID() uint32 {
+	return IDPlanetRegisterServer
+}
+
+func (pk *PlanetRegisterServer) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	w.String(&pk.Server.Name)
+	w.String(&pk.Server.Address.Address)
+	w.Uint16(&pk.Server.Address.Port)
+	w.String(&pk.Server.Group)
+	maxPlayers := int32(pk.Server.MaxPlayers)
+	w.Varint32(&maxPlayers)
+	l := uint32(len(pk.Server.Tags))
+	w.Varuint32(&l)
+	for _, v := range pk.Server.Tags {
+		w.String(&v)
+	}
+}
+
+func (pk *PlanetRegisterServer) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	r.String(&pk.Server.Name)
+	r.String(&pk.Server.Address.Address)
+	r.Uint16(&pk.Server.Address.Port)
+	r.String(&pk.Server.Group)
+	var maxPlayers int32
+	r.Varint32(&maxPlayers)
+	pk.Server.MaxPlayers = int(maxPlayers)
+	var count uint32
+	r.Varuint32(&count)
+	r.LimitUint32(count, 256)
+	pk.Server.Tags = make([]string, count)
+	for i := uint32(0); i < count; i++ {
+		r.String(&pk.Server.Tags[i])
+	}
+}
+
+/*
+PlanetRegisterServerResponse is sent to a planet once the server it registered was added or rejected.
+*/
+type PlanetRegisterServerResponse struct {
+	/*
+		RequestID is the RequestID of the PlanetRegisterServer this is a response to
+	*/
+	RequestID uint32
+
+	/*
+		Status is one of the PlanetStatus constants
+	*/
+	Status uint8
+
+	/*
+		Error is a description of why the server was rejected, it is empty if Status is PlanetStatusOK
+	*/
+	Error string
+}
+
+func (pk *PlanetRegisterServerResponse) ID() uint32 {
+	return IDPlanetRegisterServerResponse
+}
+
+func (pk *PlanetRegisterServerResponse) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	w.Uint8(&pk.Status)
+	w.String(&pk.Error)
+}
+
+func (pk *PlanetRegisterServerResponse) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	r.Uint8(&pk.Status)
+	r.String(&pk.Error)
+}
+
+/*
+PlanetUnregisterServer is sent by a planet to remove a server it registered from the Servers of the proxy.
+*/
+type PlanetUnregisterServer struct {
+	Name string
+}
+
+func (pk *PlanetUnregisterServer) ID() uint32 {
+	return IDPlanetUnregisterServer
+}
+
+func (pk *PlanetUnregisterServer) Marshal(w *protocol.Writer) {
+	w.String(&pk.Name)
+}
+
+func (pk *PlanetUnregisterServer) Unmarshal(r *protocol.Reader) {
+	r.String(&pk.Name)
+}
@@ -0,0 +1,257 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"sync"
+)
+
+/*
+RayRegistry holds all the players connected to the proxy, it is safe for concurrent use.
+*/
+type RayRegistry struct {
+	mu         sync.RWMutex
+	identities map[string]*Ray
+	xuids      map[string]*Ray
+	names      map[string]*Ray
+}
+
+/*
+Returns a new empty RayRegistry
+*/
+func NewRayRegistry() *RayRegistry {
+	return &RayRegistry{
+		identities: make(map[string]*Ray),
+		xuids:      make(map[string]*Ray),
+		names:      make(map[string]*Ray),
+	}
+}
+
+/*
+Adds a player to the registry, replacing any player with the same identity. The player that was replaced is
+returned, or nil if there was none, it is no longer in the registry so removing it later returns false.
+*/
+func (r *RayRegistry) Add(ray *Ray) (replaced *Ray) {
+	idend := ray.IdentityData()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.identities[idend.Identity]; ok {
+		r.remove(old)
+		replaced = old
+	}
+	r.identities[idend.Identity] = ray
+	if idend.XUID != "" {
+		r.xuids[idend.XUID] = ray
+	}
+	r.names[strings.ToLower(idend.DisplayName)] = ray
+	return replaced
+}
+
+/*
+Removes a player from the registry, it returns false if the player wasn't in it.
+*/
+func (r *RayRegistry) Remove(ray *Ray) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.identities[ray.IdentityData().Identity] != ray {
+		return false
+	}
+	r.remove(ray)
+	return true
+}
+
+func (r *RayRegistry) remove(ray *Ray) {
+	idend := ray.IdentityData()
+	delete(r.identities, idend.Identity)
+	if r.xuids[idend.XUID] == ray {
+		delete(r.xuids, idend.XUID)
+	}
+	if name := strings.ToLower(idend.DisplayName); r.names[name] == ray {
+		delete(r.names, name)
+	}
+}
+
+/*
+Returns the player with the identity uuid passed.
+*/
+func (r *RayRegistry) ByIdentity(identity string) (*Ray, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ray, ok := r.identities[identity]
+	return ray, ok
+}
+
+/*
+Returns the player with the XUID passed, players that aren't logged into XBOX Live can't be found this way.
+*/
+func (r *RayRegistry) ByXUID(xuid string) (*Ray, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ray, ok := r.xuids[xuid]
+	return ray, ok
+}
+
+/*
+Returns the player with the display name passed, ignoring case.
+*/
+func (r *RayRegistry) ByName(name string) (*Ray, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ray, ok := r.names[strings.ToLower(name)]
+	return ray, ok
+}
+
+/*
+Returns all the players currently connected to the backend with the address passed.
+*/
+func (r *RayRegistry) OnServer(addr IpAddr) []*Ray {
+	var rays []*Ray
+	r.Range(func(ray *Ray) bool {
+		if remote := ray.Remote(); remote != nil && *remote.Addr() == addr {
+			rays = append(rays, ray)
+		}
+		return true
+	})
+	return rays
+}
+
+/*
+Returns a snapshot of all the players in the registry.
+*/
+func (r *RayRegistry) All() []*Ray {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rays := make([]*Ray, 0, len(r.identities))
+	for _, ray := range r.identities {
+		rays = append(rays, ray)
+	}
+	return rays
+}
+
+/*
+Calls f for every player in the registry until it returns false. f is called on a snapshot, so it may add or
+remove players itself.
+*/
+func (r *RayRegistry) Range(f func(ray *Ray) bool) {
+	for _, ray := range r.All() {
+		if !f(ray) {
+			return
+		}
+	}
+}
+
+/*
+Returns the amount of players in the registry.
+*/
+func (r *RayRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.identities)
+}
+
+/*
+PlanetRegistry holds all the planets connected to the proxy, it is safe for concurrent use.
+*/
+type PlanetRegistry struct {
+	mu      sync.RWMutex
+	planets map[uuid.UUID]*Planet
+}
+
+/*
+Returns a new empty PlanetRegistry
+*/
+func NewPlanetRegistry() *PlanetRegistry {
+	return &PlanetRegistry{planets: make(map[uuid.UUID]*Planet)}
+}
+
+/*
+Adds a planet to the registry under a new id, which is returned.
+*/
+func (r *PlanetRegistry) Add(planet *Planet) uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	planet.id = uuid.New()
+	r.planets[planet.id] = planet
+	return planet.id
+}
+
+/*
+Removes a planet from the registry, it returns false if the planet wasn't in it.
+*/
+func (r *PlanetRegistry) Remove(planet *Planet) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.planets[planet.id] != planet {
+		return false
+	}
+	delete(r.planets, planet.id)
+	return true
+}
+
+/*
+Returns the planet with the id passed.
+*/
+func (r *PlanetRegistry) Get(id uuid.UUID) (*Planet, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	planet, ok := r.planets[id]
+	return planet, ok
+}
+
+/*
+Returns a snapshot of all the planets in the registry.
+*/
+func (r *PlanetRegistry) All() []*Planet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	planets := make([]*Planet, 0, len(r.planets))
+	for _, planet := range r.planets {
+		planets = append(planets, planet)
+	}
+	return planets
+}
+
+/*
+Returns the amount of planets in the registry.
+*/
+func (r *PlanetRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.planets)
+}
@@ -0,0 +1,93 @@
+package sun
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"sync"
+	"testing"
+)
+
+func testRay(i int, addr IpAddr) *Ray {
+	return &Ray{
+		identity: login.IdentityData{Identity: uuid.New().String(), XUID: fmt.Sprint(2535400000000000 + i), DisplayName: fmt.Sprint("Player", i)},
+		remote:   &Remote{addr: addr},
+	}
+}
+
+func TestRayRegistryLookups(t *testing.T) {
+	r := NewRayRegistry()
+	lobby, game := IpAddr{Address: "127.0.0.1", Port: 19133}, IpAddr{Address: "127.0.0.1", Port: 19134}
+	a, b := testRay(1, lobby), testRay(2, game)
+	r.Add(a)
+	r.Add(b)
+
+	if ray, ok := r.ByIdentity(a.IdentityData().Identity); !ok || ray != a {
+		t.Fatal("expected to find player by identity")
+	}
+	if ray, ok := r.ByXUID(b.IdentityData().XUID); !ok || ray != b {
+		t.Fatal("expected to find player by xuid")
+	}
+	if ray, ok := r.ByName("PLAYER1"); !ok || ray != a {
+		t.Fatal("expected to find player by name ignoring case")
+	}
+	if rays := r.OnServer(game); len(rays) != 1 || rays[0] != b {
+		t.Fatalf("expected only the second player on %v, got %v", game.ToString(), rays)
+	}
+	if !r.Remove(a) || r.Remove(a) {
+		t.Fatal("expected the player to be removed exactly once")
+	}
+	if _, ok := r.ByName("Player1"); ok || r.Len() != 1 {
+		t.Fatal("expected the player to be gone from every index")
+	}
+}
+
+func TestRayRegistryReplace(t *testing.T) {
+	r := NewRayRegistry()
+	old := testRay(1, IpAddr{Address: "127.0.0.1", Port: 19133})
+	relog := testRay(1, IpAddr{Address: "127.0.0.1", Port: 19133})
+	relog.identity.Identity = old.identity.Identity
+	if replaced := r.Add(old); replaced != nil {
+		t.Fatalf("expected nothing to be replaced, got %v", replaced)
+	}
+	if replaced := r.Add(relog); replaced != old {
+		t.Fatalf("expected the old session to be replaced, got %v", replaced)
+	}
+	if r.Remove(old) || r.Len() != 1 {
+		t.Fatal("expected the replaced session to be gone without removing the new one")
+	}
+}
+
+func TestRayRegistryConcurrent(t *testing.T) {
+	r := NewRayRegistry()
+	p := NewPlanetRegistry()
+	servers := []IpAddr{{Address: "127.0.0.1", Port: 19133}, {Address: "127.0.0.1", Port: 19134}}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ray := testRay(w*1000+i, servers[i%len(servers)])
+				r.Add(ray)
+				_, _ = r.ByIdentity(ray.IdentityData().Identity)
+				_, _ = r.ByXUID(ray.IdentityData().XUID)
+				_, _ = r.ByName(ray.IdentityData().DisplayName)
+				_ = r.OnServer(servers[w%len(servers)])
+				r.Range(func(*Ray) bool { return true })
+				planet := &Planet{}
+				p.Add(planet)
+				_ = p.All()
+				if i%2 == 0 {
+					r.Remove(ray)
+					p.Remove(planet)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if r.Len() != 16*100 || p.Len() != 16*100 {
+		t.Fatalf("expected %v players and planets left, got %v and %v", 16*100, r.Len(), p.Len())
+	}
+}
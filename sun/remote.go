@@ -38,13 +38,73 @@ package sun
 
 import (
 	"github.com/sandertv/gophertunnel/minecraft"
+	"sync"
 )
 
 type Remote struct {
 	conn *minecraft.Conn
 	addr IpAddr
+	//counter is counted down for addr once the remote is closed, nil if the remote isn't counted
+	counter   *ServerCounter
+	closeOnce sync.Once
 }
 
 func (r *Remote) Addr() *IpAddr {
 	return &r.addr
 }
+
+/*
+Closes the connection to the server, the player is no longer counted on it.
+*/
+func (r *Remote) close() error {
+	r.closeOnce.Do(func() {
+		if r.counter != nil {
+			r.counter.Dec(r.addr)
+		}
+	})
+	return r.conn.Close()
+}
+
+/*
+ServerCounter counts the players on every server, including the ones that are still being connected to it.
+*/
+type ServerCounter struct {
+	mu     sync.Mutex
+	counts map[IpAddr]int
+}
+
+/*
+Returns a new empty ServerCounter.
+*/
+func NewServerCounter() *ServerCounter {
+	return &ServerCounter{counts: make(map[IpAddr]int)}
+}
+
+/*
+Returns the amount of players on the server with the address passed.
+*/
+func (c *ServerCounter) Count(addr IpAddr) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[addr]
+}
+
+/*
+Counts a player on the server with the address passed, it is called before the server is dialed.
+*/
+func (c *ServerCounter) Inc(addr IpAddr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[addr]++
+}
+
+/*
+Counts a player off the server with the address passed, once the dial failed or the player left it.
+*/
+func (c *ServerCounter) Dec(addr IpAddr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[addr]--; c.counts[addr] <= 0 {
+		delete(c.counts, addr)
+	}
+}
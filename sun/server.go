@@ -0,0 +1,220 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"strings"
+	"sync"
+)
+
+/*
+Server is a backend server the proxy knows by name.
+*/
+type Server struct {
+	/*
+		The name the server is referred to by, like "lobby-1" or "bedwars"
+	*/
+	Name string
+
+	Address IpAddr
+
+	/*
+		The group the server belongs to, like "lobby"
+	*/
+	Group string
+
+	/*
+		The maximum amount of players the server takes, 0 if there is no limit
+	*/
+	MaxPlayers int
+
+	Tags []string
+}
+
+/*
+ServerRegistry holds the servers the proxy knows by name, it is safe for concurrent use.
+*/
+type ServerRegistry struct {
+	mu      sync.RWMutex
+	servers map[string]Server
+	//addrs holds the lower case names of the servers at every address, in the order they were added
+	addrs map[IpAddr][]string
+}
+
+/*
+Returns a new ServerRegistry holding the servers passed.
+*/
+func NewServerRegistry(servers []Server) *ServerRegistry {
+	r := &ServerRegistry{servers: make(map[string]Server), addrs: make(map[IpAddr][]string)}
+	for _, server := range servers {
+		r.Add(server)
+	}
+	return r
+}
+
+/*
+Adds a server to the registry, replacing any server with the same name.
+*/
+func (r *ServerRegistry) Add(server Server) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := strings.ToLower(server.Name)
+	if old, ok := r.servers[name]; ok {
+		r.unindex(old.Address, name)
+	}
+	r.servers[name] = server
+	r.addrs[server.Address] = append(r.addrs[server.Address], name)
+}
+
+/*
+Removes the name passed from the names of the servers at an address, other servers at the address stay.
+*/
+func (r *ServerRegistry) unindex(addr IpAddr, name string) {
+	names := r.addrs[addr]
+	for i, n := range names {
+		if n == name {
+			names = append(names[:i:i], names[i+1:]...)
+			break
+		}
+	}
+	if len(names) == 0 {
+		delete(r.addrs, addr)
+		return
+	}
+	r.addrs[addr] = names
+}
+
+/*
+Removes the server with the name passed from the registry, it returns false if there was no such server.
+*/
+func (r *ServerRegistry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = strings.ToLower(name)
+	server, ok := r.servers[name]
+	if !ok {
+		return false
+	}
+	delete(r.servers, name)
+	r.unindex(server.Address, name)
+	return true
+}
+
+/*
+Removes the server by the name of the server passed, but only if it still has the same address. This keeps a
+server that was replaced since from being removed.
+*/
+func (r *ServerRegistry) RemoveMatching(server Server) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := strings.ToLower(server.Name)
+	current, ok := r.servers[name]
+	if !ok || current.Address != server.Address {
+		return false
+	}
+	delete(r.servers, name)
+	r.unindex(current.Address, name)
+	return true
+}
+
+/*
+Returns the server with the name passed, ignoring case.
+*/
+func (r *ServerRegistry) ByName(name string) (Server, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	server, ok := r.servers[strings.ToLower(name)]
+	return server, ok
+}
+
+/*
+Returns the server with the address passed, if several servers share the address the one added first is returned.
+*/
+func (r *ServerRegistry) ByAddr(addr IpAddr) (Server, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names, ok := r.addrs[addr]
+	if !ok {
+		return Server{}, false
+	}
+	return r.servers[names[0]], true
+}
+
+/*
+Returns a snapshot of all the servers in the registry.
+*/
+func (r *ServerRegistry) All() []Server {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	servers := make([]Server, 0, len(r.servers))
+	for _, server := range r.servers {
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+/*
+Resolves an address that may refer to a server by name, if the port is 0 the address is taken as the name of
+a server. Resolve returns false if there is no server by that name.
+*/
+func (r *ServerRegistry) Resolve(addr IpAddr) (IpAddr, bool) {
+	if addr.Port != 0 {
+		return addr, true
+	}
+	server, ok := r.ByName(addr.Address)
+	return server.Address, ok
+}
+
+/*
+Resolves a server given as either a name or a "host:port" string into a "host:port" string.
+*/
+func (r *ServerRegistry) ResolveString(server string) string {
+	if s, ok := r.ByName(server); ok {
+		return s.Address.ToString()
+	}
+	return server
+}
+
+/*
+Returns the name of the server with the address passed, or the address itself if it has no name.
+*/
+func (r *ServerRegistry) Name(addr IpAddr) string {
+	if server, ok := r.ByAddr(addr); ok {
+		return server.Name
+	}
+	return addr.ToString()
+}
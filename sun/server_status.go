@@ -0,0 +1,161 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sun
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"time"
+)
+
+/*
+ServerStatusEntry is the health of a single backend as sent in the PlanetServerStatusResponse.
+*/
+type ServerStatusEntry struct {
+	//Name is the name of the server, or its address if it has no name.
+	Name    string
+	Address IpAddr
+	//Checked is false if the server wasn't pinged yet or health checking is disabled.
+	Checked     bool
+	Up          bool
+	Latency     time.Duration
+	MOTD        string
+	PlayerCount int
+	MaxPlayers  int
+}
+
+func (e *ServerStatusEntry) marshal(w *protocol.Writer) {
+	w.String(&e.Name)
+	w.String(&e.Address.Address)
+	w.Uint16(&e.Address.Port)
+	w.Bool(&e.Checked)
+	w.Bool(&e.Up)
+	latency := e.Latency.Milliseconds()
+	w.Varint64(&latency)
+	w.String(&e.MOTD)
+	players, maxPlayers := int32(e.PlayerCount), int32(e.MaxPlayers)
+	w.Varint32(&players)
+	w.Varint32(&maxPlayers)
+}
+
+func (e *ServerStatusEntry) unmarshal(r *protocol.Reader) {
+	r.String(&e.Name)
+	r.String(&e.Address.Address)
+	r.Uint16(&e.Address.Port)
+	r.Bool(&e.Checked)
+	r.Bool(&e.Up)
+	var latency int64
+	r.Varint64(&latency)
+	e.Latency = time.Duration(latency) * time.Millisecond
+	r.String(&e.MOTD)
+	var players, maxPlayers int32
+	r.Varint32(&players)
+	r.Varint32(&maxPlayers)
+	e.PlayerCount, e.MaxPlayers = int(players), int(maxPlayers)
+}
+
+/*
+PlanetServerStatusRequest is sent by a planet to ask the proxy for the health of every backend it knows.
+*/
+type PlanetServerStatusRequest struct {
+	RequestID uint32
+}
+
+func (pk *PlanetServerStatusRequest) ID() uint32 {
+	return IDPlanetServerStatusRequest
+}
+
+func (pk *PlanetServerStatusRequest) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+}
+
+func (pk *PlanetServerStatusRequest) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+}
+
+/*
+PlanetServerStatusResponse is sent in response to a PlanetServerStatusRequest.
+*/
+type PlanetServerStatusResponse struct {
+	RequestID uint32
+	Servers   []ServerStatusEntry
+}
+
+func (pk *PlanetServerStatusResponse) ID() uint32 {
+	return IDPlanetServerStatusResponse
+}
+
+func (pk *PlanetServerStatusResponse) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	l := uint32(len(pk.Servers))
+	w.Varuint32(&l)
+	for i := range pk.Servers {
+		pk.Servers[i].marshal(w)
+	}
+}
+
+func (pk *PlanetServerStatusResponse) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	var count uint32
+	r.Varuint32(&count)
+	r.LimitUint32(count, 4096)
+	pk.Servers = make([]ServerStatusEntry, count)
+	for i := range pk.Servers {
+		pk.Servers[i].unmarshal(r)
+	}
+}
+
+/*
+Returns the health of every backend the proxy knows.
+*/
+func (s *Sun) ServerStatuses() []ServerStatusEntry {
+	var entries []ServerStatusEntry
+	for _, addr := range s.backends() {
+		entry := ServerStatusEntry{Name: s.Servers.Name(addr), Address: addr, Up: true}
+		if s.Health != nil {
+			if health, ok := s.Health.Status(addr); ok {
+				entry.Checked = true
+				entry.Up = health.Up
+				entry.Latency = health.Latency
+				entry.MOTD = health.MOTD
+				entry.PlayerCount = health.PlayerCount
+				entry.MaxPlayers = health.MaxPlayers
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,59 @@
+package sun
+
+import "testing"
+
+func TestServerRegistry(t *testing.T) {
+	lobby, game := IpAddr{Address: "127.0.0.1", Port: 19133}, IpAddr{Address: "127.0.0.1", Port: 19134}
+	r := NewServerRegistry([]Server{{Name: "Hub", Address: lobby}})
+
+	if server, ok := r.ByName("hub"); !ok || server.Address != lobby {
+		t.Fatal("expected to find the server by name ignoring case")
+	}
+	if addr, ok := r.Resolve(IpAddr{Address: "HUB"}); !ok || addr != lobby {
+		t.Fatalf("expected the name to resolve to %v, got %v", lobby.ToString(), addr.ToString())
+	}
+	if addr, ok := r.Resolve(game); !ok || addr != game {
+		t.Fatal("expected an address with a port to resolve to itself")
+	}
+	if _, ok := r.Resolve(IpAddr{Address: "missing"}); ok {
+		t.Fatal("expected an unknown name not to resolve")
+	}
+
+	//moving a server to another address drops the old address
+	r.Add(Server{Name: "hub", Address: game})
+	if _, ok := r.ByAddr(lobby); ok {
+		t.Fatal("expected the old address of the server to be gone")
+	}
+	if r.Name(game) != "hub" {
+		t.Fatalf("expected %v to be named hub, got %v", game.ToString(), r.Name(game))
+	}
+
+	//RemoveMatching only removes the server if it still has the same address
+	if r.RemoveMatching(Server{Name: "hub", Address: lobby}) {
+		t.Fatal("expected a server that was moved not to be removed")
+	}
+	if !r.RemoveMatching(Server{Name: "hub", Address: game}) || len(r.All()) != 0 {
+		t.Fatal("expected the server to be removed")
+	}
+}
+
+func TestServerRegistrySharedAddress(t *testing.T) {
+	addr := IpAddr{Address: "127.0.0.1", Port: 19133}
+	r := NewServerRegistry([]Server{{Name: "hub", Address: addr}, {Name: "lobby", Address: addr}})
+
+	if server, ok := r.ByAddr(addr); !ok || server.Name != "hub" {
+		t.Fatalf("expected the server added first, got %v", server.Name)
+	}
+	if !r.Remove("hub") {
+		t.Fatal("expected hub to be removed")
+	}
+	if server, ok := r.ByAddr(addr); !ok || server.Name != "lobby" {
+		t.Fatal("expected the address to still belong to lobby")
+	}
+	if !r.Remove("lobby") {
+		t.Fatal("expected lobby to be removed")
+	}
+	if _, ok := r.ByAddr(addr); ok || r.Name(addr) != addr.ToString() {
+		t.Fatal("expected the address to be gone once no server uses it")
+	}
+}
@@ -37,14 +37,16 @@ SOFTWARE.
 package sun
 
 import (
+	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 	"github.com/sandertv/gophertunnel/minecraft/text"
 	"go.uber.org/atomic"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 )
@@ -52,27 +54,75 @@ import (
 var emptychunk = make([]byte, 257)
 
 type Sun struct {
-	Listener  *minecraft.Listener
-	Rays      map[string]*Ray
-	Hub       IpAddr
-	Planets   map[uuid.UUID]*Planet
-	PListener net.Listener
-	Status    StatusProvider
-	Key string
-	PWarnings map[string]int
-	PCooldowns map[string]time.Time
+	Listener         *minecraft.Listener
+	Rays             *RayRegistry
+	ServerCounts     *ServerCounter
+	Hub              IpAddr
+	Servers          *ServerRegistry
+	Hubs             *HubBalancer
+	Health           *HealthChecker
+	Planets          *PlanetRegistry
+	PListener        net.Listener
+	Status           StatusProvider
+	Counter          *PlayerCounter
+	MOTD             *MOTD
+	Maintenance      *Maintenance
+	Bans             *BanList
+	WhitelistMessage string
+	Commands         *PlayerCommands
+	Permissions      *Permissions
+	Events           *Events
+	Middleware       *Middleware
+	Channels         *Channels
+	ChatFilter       *ChatFilter
+	Key              string
+	PWarnings        map[string]int
+	PCooldowns       map[string]time.Time
+	//pmu guards PWarnings and PCooldowns
+	pmu sync.Mutex
+	//IpForwarding specifies if the real address of players should be forwarded to the backends
+	IpForwarding bool
+	//ForwardingKey is the key the forwarded addresses are signed with
+	ForwardingKey string
+	//TransferMode is TransferSeamless or TransferLegacy
+	TransferMode string
+	//Fallbacks are the servers players are moved to in order when their server goes down and the Hub is down too
+	Fallbacks []IpAddr
+	//ShutdownFallback is the server players are transferred to when the proxy shuts down
+	ShutdownFallback IpAddr
+	//ShutdownMessage is the message players are disconnected with when the proxy shuts down
+	ShutdownMessage string
+	//close is closed once the proxy starts shutting down
+	close     chan struct{}
+	closeOnce sync.Once
+	//wg tracks every routine started for players and planets
+	wg sync.WaitGroup
+	//config holds the config the proxy was started or last reloaded with
+	config   Config
+	configMu sync.RWMutex
 }
 
 type StatusProvider struct {
 	ogs     minecraft.ServerStatus
 	playerc *atomic.Int64
+	counter *PlayerCounter
+	motd    *MOTD
+	maint   *Maintenance
 }
 
 func (s StatusProvider) ServerStatus(_ int, _ int) minecraft.ServerStatus {
+	online, max := s.counter.Count()
+	name := s.ogs.ServerName
+	values := MOTDValues{Online: online, Max: max, ServersUp: s.counter.ServersUp(), Version: protocol.CurrentVersion}
+	if s.maint.Enabled() {
+		name = RenderMOTD(s.maint.MOTD, values)
+	} else if s.motd.Template() != "" {
+		name = s.motd.Render(values)
+	}
 	return minecraft.ServerStatus{
-		ServerName:  s.ogs.ServerName,
-		PlayerCount: int(s.playerc.Load()),
-		MaxPlayers:  s.ogs.MaxPlayers,
+		ServerName:  name,
+		PlayerCount: online,
+		MaxPlayers:  max,
 		ShowVersion: s.ogs.ShowVersion,
 	}
 }
@@ -81,7 +131,30 @@ func (s StatusProvider) ServerStatus(_ int, _ int) minecraft.ServerStatus {
 Returns a new sun with config the specified config hence W
 */
 func NewSunW(config Config) (*Sun, error) {
-	status := StatusProvider{config.Status, atomic.NewInt64(0)}
+	playerc := atomic.NewInt64(0)
+	counter, err := NewPlayerCounter(config.PlayerCount.Source, config.PlayerCount.MaxPolicy, config.Status.MaxPlayers, playerc)
+	if err != nil {
+		return nil, err
+	}
+	if counter.Source == "backends" && !config.HealthCheck.Enabled {
+		return nil, fmt.Errorf("the backends player count source needs HealthCheck to be enabled")
+	}
+	transferMode := strings.ToLower(config.Proxy.TransferMode)
+	switch transferMode {
+	case "", TransferSeamless, TransferLegacy:
+	default:
+		return nil, fmt.Errorf("unknown transfer mode %v", config.Proxy.TransferMode)
+	}
+	motd := NewMOTD(config.MOTD.Messages, time.Duration(config.MOTD.Interval)*time.Second)
+	bans, err := LoadBanList(config.Bans.File)
+	if err != nil {
+		return nil, err
+	}
+	maintenance := NewMaintenance(config.Maintenance.Enabled, config.Maintenance.Message, config.Maintenance.MOTD, config.Maintenance.Whitelist)
+	//names can only be trusted if Xbox Live vouches for them
+	maintenance.MatchNames = config.Proxy.XboxAuthentication
+	bans.MatchNames = config.Proxy.XboxAuthentication
+	status := StatusProvider{config.Status, playerc, counter, motd, maintenance}
 	listener, err := minecraft.ListenConfig{
 		AuthenticationDisabled: !config.Proxy.XboxAuthentication,
 		StatusProvider:         status,
@@ -90,33 +163,155 @@ func NewSunW(config Config) (*Sun, error) {
 	if err != nil {
 		return nil, err
 	}
+	registerPackets()
+	s := &Sun{Listener: listener,
+		Status:           status,
+		Counter:          counter,
+		MOTD:             motd,
+		Maintenance:      maintenance,
+		Bans:             bans,
+		WhitelistMessage: config.Bans.WhitelistMessage,
+		Commands:         NewPlayerCommands(),
+		Permissions:      NewPermissions(config.Commands.DefaultPermissions, config.Commands.Permissions),
+		Events:           NewEvents(),
+		Middleware:       NewMiddleware(),
+		Channels:         NewChannels(config.Chat.Channels),
+		Rays:             NewRayRegistry(),
+		ServerCounts:     NewServerCounter(),
+		Hub:              config.Hub,
+		Servers:          NewServerRegistry(config.Servers),
+		Planets:          NewPlanetRegistry(),
+		IpForwarding:     config.Proxy.IpForwarding,
+		ForwardingKey:    config.Proxy.ForwardingKey,
+		TransferMode:     transferMode,
+		Fallbacks:        config.Fallbacks,
+		ShutdownFallback: config.Shutdown.Fallback,
+		ShutdownMessage:  config.Shutdown.Message,
+		close:            make(chan struct{}),
+		config:           config}
+	s.Permissions.MatchNames = config.Proxy.XboxAuthentication
+	if config.ChatFilter.Enabled {
+		action, err := ParseFilterAction(config.ChatFilter.Action)
+		if err != nil {
+			return nil, err
+		}
+		s.ChatFilter, err = NewChatFilter(config.ChatFilter.Words, config.ChatFilter.Patterns, action)
+		if err != nil {
+			return nil, err
+		}
+		s.ChatFilter.MaxCaps = config.ChatFilter.MaxCaps
+		s.ChatFilter.MinCapsLength = config.ChatFilter.MinCapsLength
+		s.ChatFilter.SpamLimit = config.ChatFilter.SpamLimit
+		s.ChatFilter.SpamInterval = time.Duration(config.ChatFilter.SpamInterval) * time.Second
+		s.ChatFilter.RepeatLimit = config.ChatFilter.RepeatLimit
+	}
+	s.registerPlayerCommands()
+	s.registerChatFilter()
+	s.registerChannels()
+	s.registerAnnouncements()
+	s.registerWorldState()
+	strategy, err := NewHubStrategy(config.HubBalancer.Strategy, s)
+	if err != nil {
+		return nil, err
+	}
+	s.Hubs = NewHubBalancer(s.Servers, config.HubBalancer.Group, config.Hub, strategy, time.Duration(config.HubBalancer.Cooldown)*time.Second)
+	if config.HealthCheck.Enabled {
+		s.Health = NewHealthChecker(s.backends,
+			time.Duration(config.HealthCheck.Interval)*time.Second,
+			time.Duration(config.HealthCheck.Timeout)*time.Second,
+			config.HealthCheck.UpThreshold, config.HealthCheck.DownThreshold)
+		s.Hubs.Health = s.Health
+	}
+	counter.servers = s.Servers
+	counter.health = s.Health
+	counter.peers = config.PlayerCount.Peers
+	counter.peerKey = config.Tcp.Key
 	if config.Tcp.Enabled {
 		plistener, err := net.Listen("tcp", ":42069")
 		if err != nil {
 			return nil, err
 		}
-		registerPackets()
-		return &Sun{Listener: listener,
-			PListener: plistener,
-			PCooldowns: make(map[string]time.Time),
-			PWarnings: make(map[string]int),
-			Status:    status,
-			Rays: make(map[string]*Ray,
-				config.Status.MaxPlayers),
-			Hub: config.Hub, Planets: make(map[uuid.UUID]*Planet),
-			Key: config.Tcp.Key}, nil
+		s.PListener = plistener
+		s.PCooldowns = make(map[string]time.Time)
+		s.PWarnings = make(map[string]int)
+		s.Key = config.Tcp.Key
 	}
-	registerPackets()
-	return &Sun{Listener: listener,
-		Status:    status,
-		Rays: make(map[string]*Ray,
-			config.Status.MaxPlayers),
-		Hub: config.Hub, Planets: make(map[uuid.UUID]*Planet)}, nil
+	return s, nil
+}
+
+/*
+Reloads the servers, the MOTDs and the maintenance whitelist from the config and the bans from their file.
+Everything else only changes when the proxy is restarted.
+*/
+func (s *Sun) Reload() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyConfig(config)
+	return s.Bans.Reload()
+}
+
+/*
+Applies the parts of the config passed that can be reloaded. Servers and whitelist entries that were removed from
+the config are removed, those added by planets or commands are left alone.
+*/
+func (s *Sun) applyConfig(config Config) {
+	s.configMu.Lock()
+	old := s.config
+	s.config = config
+	s.configMu.Unlock()
+	for _, server := range old.Servers {
+		if !s.configServer(server.Name) {
+			s.Servers.RemoveMatching(server)
+		}
+	}
+	for _, server := range config.Servers {
+		s.Servers.Add(server)
+	}
+	s.MOTD.SetTemplates(config.MOTD.Messages)
+	for _, entry := range old.Maintenance.Whitelist {
+		if !containsFold(config.Maintenance.Whitelist, entry) {
+			s.Maintenance.RemoveWhitelist(entry)
+		}
+	}
+	for _, entry := range config.Maintenance.Whitelist {
+		s.Maintenance.AddWhitelist(entry)
+	}
+}
+
+/*
+Returns true if the list passed holds the string passed, ignoring case.
+*/
+func containsFold(list []string, str string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, str) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Returns true if a server with the name passed is set in the config, ignoring case.
+*/
+func (s *Sun) configServer(name string) bool {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	for _, server := range s.config.Servers {
+		if strings.EqualFold(server.Name, name) {
+			return true
+		}
+	}
+	return false
 }
 
 func registerPackets() {
 	packet.Register(IDRayTransfer, func() packet.Packet { return &Transfer{} })
 	packet.Register(IDRayText, func() packet.Packet { return &Text{} })
+	packet.Register(IDRayTitle, func() packet.Packet { return &Title{} })
+	packet.Register(IDRayBossBar, func() packet.Packet { return &BossBar{} })
+	packet.Register(IDRaySound, func() packet.Packet { return &Sound{} })
 }
 
 /*
@@ -131,43 +326,48 @@ func NewSun() (*Sun, error) {
 }
 
 func (s *Sun) main() {
-	defer s.Listener.Close()
+	if s.Health != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.Health.run(s.close)
+		}()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.Bans.runPrune(time.Minute, s.close)
+	}()
+	if s.Counter.Source == "peers" {
+		s.configMu.RLock()
+		interval := time.Duration(s.config.HealthCheck.Interval) * time.Second
+		timeout := time.Duration(s.config.HealthCheck.Timeout) * time.Second
+		s.configMu.RUnlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.Counter.runPeers(interval, timeout, s.close)
+		}()
+	}
 	if s.PListener != nil {
+		s.wg.Add(1)
 		go func() {
+			defer s.wg.Done()
 			for {
 				conn, err := s.PListener.Accept()
 				if err != nil {
+					if s.closing() {
+						return
+					}
 					log.Println(err)
 					continue
 				}
-				pl := &Planet{conn: conn}
-				if tl, ok := s.PCooldowns[pl.conn.RemoteAddr().String()]; ok {
-					if time.Now().Before(tl) {
-						_ = pl.WritePacket(&PlanetDisconnect{Message: fmt.Sprintf("You are on cooldown for %v seconds!", time.Now().Sub(s.PCooldowns[pl.conn.RemoteAddr().String()]).Seconds())})
-						_ = pl.conn.Close()
-						continue
-					}
-					delete(s.PCooldowns, conn.RemoteAddr().String())
-				}
-				pk, err := pl.ReadPacket()
-				if pk, ok := pk.(*PlanetAuth); ok {
-					if pk.Key == s.Key {
-						s.AddPlanet(pl)
-						continue
-					}
-				}
-				if _, ok := s.PWarnings[pl.conn.RemoteAddr().String()]; !ok {
-					s.PWarnings[pl.conn.RemoteAddr().String()] = 3
-				}
-				s.PWarnings[pl.conn.RemoteAddr().String()]--
-				if s.PWarnings[pl.conn.RemoteAddr().String()] <= 0 {
-					s.PWarnings[pl.conn.RemoteAddr().String()] = 3
-					s.PCooldowns[pl.conn.RemoteAddr().String()] = time.Now().Add(300 * time.Second)
-					_ = pl.WritePacket(&PlanetDisconnect{Message: fmt.Sprintf("You are on cooldown for %v seconds!", time.Now().Sub(s.PCooldowns[pl.conn.RemoteAddr().String()]).Seconds())})
-					_ = pl.conn.Close()
-				}
-				_ = pl.WritePacket(&PlanetDisconnect{Message: fmt.Sprintf("Invalid Authorization Key Provided %v Tries Remain Until A 300 Second Cooldown!", s.PWarnings[pl.conn.RemoteAddr().String()])})
-				continue
+				//authenticate in a new routine so a slow planet doesn't hold up the others
+				s.wg.Add(1)
+				go func() {
+					defer s.wg.Done()
+					s.authPlanet(&Planet{conn: conn})
+				}()
 			}
 		}()
 	}
@@ -175,96 +375,324 @@ func (s *Sun) main() {
 		//Listener won't be closed unless it is manually done
 		conn, err := s.Listener.Accept()
 		if err != nil {
+			if s.closing() {
+				return
+			}
 			log.Println(err)
 			continue
 		}
-		ray := &Ray{conn: conn.(*minecraft.Conn)}
-		rconn, err := minecraft.Dialer{
-			ClientData:   ray.conn.ClientData(),
-			IdentityData: ray.conn.IdentityData()}.Dial("raknet", s.Hub.ToString())
-		if err != nil {
-			log.Println(err)
-			_ = s.Listener.Disconnect(conn.(*minecraft.Conn),
-				text.Colourf("<red>You Have been Disconnected!</red>"))
-			continue
-		}
-		ray.remoteMu.Lock()
-		ray.remote = &Remote{conn: rconn, addr: s.Hub}
-		ray.remoteMu.Unlock()
-		s.MakeRay(ray)
+		//connect in a new routine so a slow hub doesn't hold up the others
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.connect(conn.(*minecraft.Conn))
+		}()
+	}
+}
+
+/*
+Dials the hub for a new player and readies them.
+*/
+func (s *Sun) connect(conn *minecraft.Conn) {
+	if s.closing() {
+		_ = s.Listener.Disconnect(conn, s.ShutdownMessage)
+		return
+	}
+	if ban, ok := s.Bans.Check(conn.IdentityData(), hostIP(conn.RemoteAddr())); ok {
+		_ = s.Listener.Disconnect(conn, ban.Message())
+		return
+	}
+	if !s.Bans.Whitelisted(conn.IdentityData()) {
+		_ = s.Listener.Disconnect(conn, s.WhitelistMessage)
+		return
+	}
+	if !s.Maintenance.Allowed(conn.IdentityData()) {
+		_ = s.Listener.Disconnect(conn, s.Maintenance.Message)
+		return
+	}
+	ev := &PreLoginEvent{Conn: conn}
+	s.Events.firePreLogin(ev)
+	if ev.Cancelled {
+		_ = s.Listener.Disconnect(conn, ev.KickMessage)
+		return
+	}
+	ray := newRay(conn)
+	remote, err := s.dialHub(ray)
+	if err != nil {
+		log.Println(err)
+		_ = s.Listener.Disconnect(conn,
+			text.Colourf("<red>You Have been Disconnected!</red>"))
+		return
 	}
+	ray.setRemote(remote)
+	s.MakeRay(ray)
 }
 
 /*
-Starts the proxy.
+Starts the proxy, it returns once the proxy is shut down.
 */
 func (s *Sun) Start() {
+	s.wg.Add(1)
+	defer s.wg.Done()
 	s.main()
 }
 
+/*
+Returns true once the proxy started shutting down.
+*/
+func (s *Sun) closing() bool {
+	select {
+	case <-s.close:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+Close shuts the proxy down, waiting for every player and planet to be disconnected.
+*/
+func (s *Sun) Close() error {
+	return s.Shutdown(context.Background())
+}
+
+/*
+Shutdown stops accepting new players and planets, then transfers every player to the ShutdownFallback or
+disconnects them with the ShutdownMessage, closes every planet and waits for all their routines to exit.
+If the context is done before that, Shutdown returns its error.
+*/
+func (s *Sun) Shutdown(ctx context.Context) error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.close)
+		if s.PListener != nil {
+			_ = s.PListener.Close()
+		}
+		emptyIp := IpAddr{}
+		for _, ray := range s.Rays.All() {
+			if s.ShutdownFallback != emptyIp {
+				_ = ray.conn.WritePacket(&packet.Transfer{Address: s.ShutdownFallback.Address, Port: s.ShutdownFallback.Port})
+				s.closeRay(ray)
+				continue
+			}
+			s.DisconnectRay(ray, s.ShutdownMessage)
+		}
+		for _, planet := range s.Planets.All() {
+			_ = planet.WritePacket(&PlanetDisconnect{Message: "The proxy is shutting down!"})
+			_ = planet.Close()
+		}
+		//this also closes the connection of any player still logging in
+		err = s.Listener.Close()
+	})
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 /*
 Adds a player to the sun and readies them
 */
 func (s *Sun) MakeRay(ray *Ray) {
 	//start the player up
 	var g sync.WaitGroup
+	var failed atomic.Bool
 	g.Add(2)
 	go func() {
+		defer g.Done()
 		if err := ray.conn.StartGame(ray.Remote().conn.GameData()); err != nil {
-			return // Connection was closed by server
+			failed.Store(true) // Connection was closed by server
 		}
-		g.Done()
 	}()
 	go func() {
+		defer g.Done()
 		if err := ray.Remote().conn.DoSpawn(); err != nil {
-			return // Connection was closed by server
+			failed.Store(true) // Connection was closed by server
 		}
-		g.Done()
 	}()
 	g.Wait()
+	if failed.Load() || s.closing() {
+		s.DisconnectRay(ray, text.Colourf("<red>You Have been Disconnected!</red>"))
+		return
+	}
 	//start translator
 	ray.initTranslators(ray.conn.GameData())
+	ray.setPosition(ray.conn.GameData().PlayerPosition)
+	ray.world.setDimension(ray.conn.GameData().Dimension)
 	//Add to player count
 	s.Status.playerc.Add(1)
 	//add to player list
-	s.Rays[ray.conn.IdentityData().Identity] = ray
+	if old := s.Rays.Add(ray); old != nil {
+		//the old session no longer counts once it is replaced, as closing it won't find it in the list
+		s.Status.playerc.Dec()
+		s.Events.fireDisconnect(&DisconnectEvent{Ray: old})
+		s.DisconnectRay(old, text.Colourf("<red>You logged in from another location</red>"))
+	}
 	//Start the two listener functions
 	s.handleRay(ray)
+	s.Events.firePostLogin(&PostLoginEvent{Ray: ray})
+	s.Events.fireServerConnected(&ServerConnectedEvent{Ray: ray, Server: *ray.Remote().Addr()})
 }
 
 /*
 Closes a players session cleanly with a nice disconnection message!
 */
 func (s *Sun) BreakRay(ray *Ray) {
-	_ = s.Listener.Disconnect(ray.conn, text.Colourf("<red>You Have been Disconnected!</red>"))
-	_ = ray.Remote().conn.Close()
-	s.Status.playerc.Dec()
-	delete(s.Rays, ray.conn.IdentityData().Identity)
+	s.DisconnectRay(ray, text.Colourf("<red>You Have been Disconnected!</red>"))
+}
+
+/*
+Disconnects a player from the proxy with the message passed.
+*/
+func (s *Sun) DisconnectRay(ray *Ray, message string) {
+	_ = s.Listener.Disconnect(ray.conn, message)
+	s.closeRay(ray)
+}
+
+/*
+Closes the connections of a player and removes them from the proxy, without telling the player why.
+*/
+func (s *Sun) closeRay(ray *Ray) {
+	ray.closeOnce.Do(func() {
+		close(ray.closed)
+	})
+	_ = ray.conn.Close()
+	if remote := ray.Remote(); remote != nil {
+		_ = remote.close()
+	}
+	//a transfer that didn't finish yet still counts the player on the server they were sent to
+	if bufferC := ray.takeBufferConn(); bufferC != nil {
+		_ = bufferC.close()
+	}
+	//only count the player down once if they are closed more than once
+	if s.Rays.Remove(ray) {
+		s.Status.playerc.Dec()
+		s.Events.fireDisconnect(&DisconnectEvent{Ray: ray})
+	}
 }
 
 func (s *Sun) SendMessageToServers(Message string, Servers []string) {
-	for _, server := range Servers {
-		for _, ray := range s.Rays {
-			if ray.Remote().Addr().ToString() == server {
-				_ = ray.conn.WritePacket(&packet.Text{Message: Message, TextType: packet.TextTypeRaw})
+	if len(Servers) == 0 {
+		return
+	}
+	s.sendMessageWhere(Message, Servers, nil)
+}
+
+/*
+Sends a message to the players on the servers passed, or on every server if none are passed, for which the filter
+returns true. A nil filter accepts every player.
+*/
+func (s *Sun) sendMessageWhere(message string, servers []string, filter func(ray *Ray) bool) {
+	s.sendWhere([]packet.Packet{&packet.Text{Message: message, TextType: packet.TextTypeRaw}}, servers, filter)
+}
+
+/*
+Broadcasts a Text the way its Type says to the players on its Servers, or to every player if it has none.
+*/
+func (s *Sun) Broadcast(text *Text) {
+	s.sendWhere(text.packets(), text.Servers, nil)
+}
+
+/*
+Sends packets to the players on the servers passed, or on every server if none are passed, for which the filter
+returns true. A nil filter accepts every player.
+*/
+func (s *Sun) sendWhere(pks []packet.Packet, servers []string, filter func(ray *Ray) bool) {
+	addrs := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		//the server may be referred to by name
+		addrs[s.Servers.ResolveString(server)] = true
+	}
+	s.Rays.Range(func(ray *Ray) bool {
+		if len(addrs) > 0 && !addrs[ray.Remote().Addr().ToString()] {
+			return true
+		}
+		if filter == nil || filter(ray) {
+			for _, pk := range pks {
+				_ = ray.conn.WritePacket(pk)
 			}
 		}
-	}
+		return true
+	})
 }
 
 /*
 SendMessage is used for sending a Sun wide message to all the connected clients
 */
 func (s *Sun) SendMessage(Message string) {
-	for _, ray := range s.Rays {
+	s.Rays.Range(func(ray *Ray) bool {
 		//Send raw chat to each player as client will accept it
 		_ = ray.conn.WritePacket(&packet.Text{Message: Message, TextType: packet.TextTypeRaw})
-	}
+		return true
+	})
 }
 
 func (s *Sun) AddPlanet(planet *Planet) {
-	id := uuid.New()
-	planet.id = id
-	s.Planets[id] = planet
+	s.Planets.Add(planet)
 	s.handlePlanet(planet)
+	s.Events.firePlanetConnected(&PlanetConnectedEvent{Planet: planet})
+}
+
+/*
+Reads the PlanetAuth packet of a new planet, adding it if the key is right and putting the address of the planet
+on a cooldown after 3 wrong keys.
+*/
+func (s *Sun) authPlanet(pl *Planet) {
+	host, _, _ := net.SplitHostPort(pl.conn.RemoteAddr().String())
+	s.pmu.Lock()
+	if tl, ok := s.PCooldowns[host]; ok {
+		if time.Now().Before(tl) {
+			s.pmu.Unlock()
+			s.Events.firePlanetAuthFailed(&PlanetAuthFailedEvent{Addr: pl.conn.RemoteAddr(), Reason: "on cooldown"})
+			_ = pl.WritePacket(&PlanetDisconnect{Message: fmt.Sprintf("You are on cooldown for %v seconds!", int(time.Until(tl).Seconds()))})
+			_ = pl.conn.Close()
+			return
+		}
+		delete(s.PCooldowns, host)
+	}
+	s.pmu.Unlock()
+	pk, _ := pl.ReadPacket()
+	switch pk := pk.(type) {
+	case *PlanetAuth:
+		if pk.Key == s.Key {
+			if err := pl.WritePacket(&PlanetAuthResponse{}); err != nil {
+				_ = pl.conn.Close()
+				return
+			}
+			s.AddPlanet(pl)
+			return
+		}
+	case *PlanetPeerCountRequest:
+		if pk.Key == s.Key {
+			//only the players on this proxy, so peers don't count each other over and over
+			_ = pl.WritePacket(&PlanetPeerCountResponse{Count: int32(s.Counter.local.Load())})
+			_ = pl.conn.Close()
+			return
+		}
+	}
+	s.pmu.Lock()
+	if _, ok := s.PWarnings[host]; !ok {
+		s.PWarnings[host] = 3
+	}
+	s.PWarnings[host]--
+	warnings := s.PWarnings[host]
+	if warnings <= 0 {
+		s.PWarnings[host] = 3
+		s.PCooldowns[host] = time.Now().Add(300 * time.Second)
+	}
+	s.pmu.Unlock()
+	s.Events.firePlanetAuthFailed(&PlanetAuthFailedEvent{Addr: pl.conn.RemoteAddr(), Reason: "invalid key"})
+	if warnings <= 0 {
+		_ = pl.WritePacket(&PlanetDisconnect{Message: "You are on cooldown for 300 seconds!"})
+	} else {
+		_ = pl.WritePacket(&PlanetDisconnect{Message: fmt.Sprintf("Invalid Authorization Key Provided %v Tries Remain Until A 300 Second Cooldown!", warnings)})
+	}
+	_ = pl.conn.Close()
 }
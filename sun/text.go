@@ -36,21 +36,53 @@ SOFTWARE.
 
 package sun
 
-import "github.com/sandertv/gophertunnel/minecraft/protocol"
+import (
+	"errors"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+)
+
+/*
+TextVersion is the version of the Text wire format written by this proxy. Version 0 packets, sent by backends
+from before the Message was transmitted, only hold the Servers and are still accepted.
+*/
+const TextVersion uint8 = 1
+
+/*
+The ways a Text message can be shown to the players.
+*/
+const (
+	TextRaw uint8 = iota
+	TextPopup
+	TextTip
+	TextJukeboxPopup
+	TextTitle
+)
 
 /*
 Text is sent by the server to send a message to all the connected players on the proxy.
 */
 type Text struct {
 	/*
-	Servers is an array of strings that contains the servers IP addresses the text message should be broadcast to
+		Servers is an array of strings that contains the servers IP addresses or names the text message should be broadcast to
 	*/
 	Servers []string
 
 	/*
-	The text message
+		The text message
 	*/
 	Message string
+
+	/*
+		Type is how the message is shown, one of the Text constants such as TextRaw
+	*/
+	Type uint8
+
+	/*
+		Version is the version of the wire format the packet was read with, it is always written as TextVersion
+	*/
+	Version uint8
 }
 
 func (pk *Text) ID() uint32 {
@@ -63,6 +95,11 @@ func (pk *Text) Marshal(w *protocol.Writer) {
 	for _, v := range pk.Servers {
 		w.String(&v)
 	}
+	//the fields after the servers were added in version 1, older readers stop before them
+	version := TextVersion
+	w.Uint8(&version)
+	w.String(&pk.Message)
+	w.Uint8(&pk.Type)
 }
 
 func (pk *Text) Unmarshal(r *protocol.Reader) {
@@ -71,6 +108,110 @@ func (pk *Text) Unmarshal(r *protocol.Reader) {
 	r.Varuint32(&count)
 	pk.Servers = make([]string, count)
 	for i := uint32(0); i < count; i++ {
-			r.String(&pk.Servers[i])
+		r.String(&pk.Servers[i])
+	}
+	//the packet may be reused, so clear what a version 0 packet doesn't set
+	pk.Version, pk.Message, pk.Type = 0, "", TextRaw
+	if !readVersion(r, &pk.Version) {
+		//a version 0 packet ends after the servers
+		return
 	}
+	r.String(&pk.Message)
+	r.Uint8(&pk.Type)
+}
+
+/*
+Reads the version byte of a packet that may end before it, it returns false if the packet ended.
+*/
+func readVersion(r *protocol.Reader, version *uint8) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err, isErr := err.(error); isErr && errors.Is(err, io.EOF) {
+				ok = false
+				return
+			}
+			panic(err)
+		}
+	}()
+	r.Uint8(version)
+	return true
+}
+
+/*
+Returns the packets that show the message to a player the way Type says.
+*/
+func (pk *Text) packets() []packet.Packet {
+	switch pk.Type {
+	case TextPopup:
+		return []packet.Packet{&packet.Text{TextType: packet.TextTypePopup, Message: pk.Message}}
+	case TextTip:
+		return []packet.Packet{&packet.Text{TextType: packet.TextTypeTip, Message: pk.Message}}
+	case TextJukeboxPopup:
+		return []packet.Packet{&packet.Text{TextType: packet.TextTypeJukeboxPopup, Message: pk.Message}}
+	case TextTitle:
+		return []packet.Packet{&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: pk.Message}}
+	}
+	return []packet.Packet{&packet.Text{TextType: packet.TextTypeRaw, Message: pk.Message}}
+}
+
+/*
+PlanetText is the Text packet sent by a planet over the tcp protocol.
+*/
+type PlanetText struct {
+	/*
+		RequestID is sent back in the PlanetTextResponse for this message
+	*/
+	RequestID uint32
+
+	Text
+}
+
+func (pk *PlanetText) ID() uint32 {
+	return IDPlanetText
+}
+
+func (pk *PlanetText) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	pk.Text.Marshal(w)
+}
+
+func (pk *PlanetText) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	pk.Text.Unmarshal(r)
+}
+
+/*
+PlanetTextResponse is sent to a planet once the message it sent was broadcast.
+*/
+type PlanetTextResponse struct {
+	/*
+		RequestID is the RequestID of the PlanetText this is a response to
+	*/
+	RequestID uint32
+
+	/*
+		Status is one of the PlanetStatus constants
+	*/
+	Status uint8
+
+	/*
+		Error is a description of why the message wasn't broadcast, it is empty if Status is PlanetStatusOK
+	*/
+	Error string
+}
+
+func (pk *PlanetTextResponse) ID() uint32 {
+	return IDPlanetTextResponse
+}
+
+func (pk *PlanetTextResponse) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	w.Uint8(&pk.Status)
+	w.String(&pk.Error)
+}
+
+func (pk *PlanetTextResponse) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	r.Uint8(&pk.Status)
+	r.String(&pk.Error)
 }
@@ -0,0 +1,28 @@
+package sun
+
+import (
+	"bytes"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"testing"
+)
+
+func TestTextVersions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	(&Text{Servers: []string{"hub"}, Message: "Hello", Type: TextPopup}).Marshal(protocol.NewWriter(buf, 0))
+	pk := &Text{}
+	pk.Unmarshal(protocol.NewReader(buf, 0))
+	if pk.Version != TextVersion || pk.Message != "Hello" || pk.Type != TextPopup || len(pk.Servers) != 1 {
+		t.Fatalf("unexpected packet %#v", pk)
+	}
+
+	//a backend from before the message was transmitted only writes the servers
+	buf.Reset()
+	w := protocol.NewWriter(buf, 0)
+	count, server := uint32(1), "hub"
+	w.Varuint32(&count)
+	w.String(&server)
+	pk.Unmarshal(protocol.NewReader(buf, 0))
+	if pk.Version != 0 || pk.Message != "" || pk.Type != TextRaw || len(pk.Servers) != 1 || pk.Servers[0] != "hub" {
+		t.Fatalf("unexpected version 0 packet %#v", pk)
+	}
+}
@@ -43,6 +43,7 @@ import (
 //Transfer is sent by the server to change a Players remote connection otherwise known as the fast transfer packet
 type Transfer struct {
 	// Address is the address of the new server, which might be either a hostname or an actual IP address.
+	// If Port is 0 it is the name of a server in the Servers of the proxy instead.
 	Address string
 	// Port is the UDP port of the new server.
 	Port uint16
@@ -63,7 +64,10 @@ func (pk *Transfer) Unmarshal(r *protocol.Reader) {
 }
 
 type PlanetTransfer struct {
+	//RequestID is sent back in the PlanetTransferResponse for this transfer
+	RequestID uint32
 	// Address is the address of the new server, which might be either a hostname or an actual IP address.
+	// If Port is 0 it is the name of a server in the Servers of the proxy instead.
 	Address string
 	// Port is the UDP port of the new server.
 	Port uint16
@@ -76,13 +80,41 @@ func (pk *PlanetTransfer) ID() uint32 {
 }
 
 func (pk *PlanetTransfer) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
 	w.String(&pk.Address)
 	w.Uint16(&pk.Port)
 	w.String(&pk.User)
 }
 
 func (pk *PlanetTransfer) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
 	r.String(&pk.Address)
 	r.Uint16(&pk.Port)
 	r.String(&pk.User)
-}
\ No newline at end of file
+}
+
+// PlanetTransferResponse is sent to a planet once the transfer it requested finished or failed
+type PlanetTransferResponse struct {
+	//RequestID is the RequestID of the PlanetTransfer this is a response to
+	RequestID uint32
+	//Status is one of the PlanetStatus constants
+	Status uint8
+	//Error is a description of why the transfer failed, it is empty if Status is PlanetStatusOK
+	Error string
+}
+
+func (pk *PlanetTransferResponse) ID() uint32 {
+	return IDPlanetTransferResponse
+}
+
+func (pk *PlanetTransferResponse) Marshal(w *protocol.Writer) {
+	w.Uint32(&pk.RequestID)
+	w.Uint8(&pk.Status)
+	w.String(&pk.Error)
+}
+
+func (pk *PlanetTransferResponse) Unmarshal(r *protocol.Reader) {
+	r.Uint32(&pk.RequestID)
+	r.Uint8(&pk.Status)
+	r.String(&pk.Error)
+}
@@ -165,6 +165,9 @@ func (r *Ray) translatePacket(pk packet.Packet) {
 }
 
 func (r *Ray) translateRuntimeID(id uint64) uint64 {
+	if id == ProxyBossEntityRuntimeID {
+		return id
+	}
 	original := r.Translations.OriginalEntityRuntimeID
 	current := r.Translations.CurrentEntityRuntimeID
 
@@ -177,6 +180,10 @@ func (r *Ray) translateRuntimeID(id uint64) uint64 {
 }
 
 func (r *Ray) translateUniqueID(id int64) int64 {
+	if id == ProxyBossEntityID {
+		//the boss bar entity of the proxy is the same on every server
+		return id
+	}
 	original := r.Translations.OriginalEntityUniqueID
 	current := r.Translations.CurrentEntityUniqueID
 
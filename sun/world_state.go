@@ -0,0 +1,297 @@
+/**
+      ___           ___           ___
+     /  /\         /__/\         /__/\
+    /  /:/_        \  \:\        \  \:\
+   /  /:/ /\        \  \:\        \  \:\
+  /  /:/ /::\   ___  \  \:\   _____\__\:\
+ /__/:/ /:/\:\ /__/\  \__\:\ /__/::::::::\
+ \  \:\/:/~/:/ \  \:\ /  /:/ \  \:\~~\~~\/
+  \  \::/ /:/   \  \:\  /:/   \  \:\  ~~~
+   \__\/ /:/     \  \:\/:/     \  \:\
+     /__/:/       \  \::/       \  \:\
+     \__\/         \__\/         \__\/
+
+MIT License
+
+Copyright (c) 2020 Jviguy
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package sun
+
+import (
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"sync"
+)
+
+/*
+gameTypeWorldDefault is the game mode of a player that plays in the game mode of the world.
+*/
+const gameTypeWorldDefault = 5
+
+/*
+The ways players can be moved between servers.
+*/
+const (
+	//TransferSeamless moves the player through a dimension they are in neither before nor after the transfer and
+	//applies the game data of the new server.
+	TransferSeamless = "seamless"
+	//TransferLegacy moves the player through the nether to the overworld and keeps the game data of the first server.
+	TransferLegacy = "legacy"
+)
+
+/*
+worldState holds what the server of a player has spawned on their client, so it can be removed again when the
+player is transferred to another server.
+*/
+type worldState struct {
+	mu sync.Mutex
+	//dim is the dimension the player is in, it is kept when the rest is cleared
+	dim        int32
+	entities   map[int64]struct{}
+	bossBars   map[int64]struct{}
+	effects    map[int32]struct{}
+	playerList map[uuid.UUID]struct{}
+	objectives map[string]struct{}
+	forms      map[uint32]struct{}
+	//staleForms are the forms the servers the player was on before sent, answers to them are dropped
+	staleForms map[uint32]struct{}
+}
+
+func newWorldState() *worldState {
+	w := &worldState{staleForms: make(map[uint32]struct{})}
+	w.clear()
+	return w
+}
+
+func (w *worldState) clear() {
+	w.entities = make(map[int64]struct{})
+	w.bossBars = make(map[int64]struct{})
+	w.effects = make(map[int32]struct{})
+	w.playerList = make(map[uuid.UUID]struct{})
+	w.objectives = make(map[string]struct{})
+	w.forms = make(map[uint32]struct{})
+}
+
+/*
+Records the changes a packet sent to the player makes, self is the runtime ID the player has on their client.
+*/
+func (w *worldState) track(pk packet.Packet, self uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch pk := pk.(type) {
+	case *packet.ChangeDimension:
+		w.dim = pk.Dimension
+	case *packet.AddActor:
+		w.entities[pk.EntityUniqueID] = struct{}{}
+	case *packet.AddPlayer:
+		w.entities[pk.EntityUniqueID] = struct{}{}
+	case *packet.AddItemActor:
+		w.entities[pk.EntityUniqueID] = struct{}{}
+	case *packet.AddPainting:
+		w.entities[pk.EntityUniqueID] = struct{}{}
+	case *packet.RemoveActor:
+		delete(w.entities, pk.EntityUniqueID)
+		delete(w.bossBars, pk.EntityUniqueID)
+	case *packet.BossEvent:
+		if pk.BossEntityUniqueID == ProxyBossEntityID {
+			//the boss bar of the proxy stays when switching servers
+			return
+		}
+		switch pk.EventType {
+		case packet.BossEventShow:
+			w.bossBars[pk.BossEntityUniqueID] = struct{}{}
+		case packet.BossEventHide:
+			delete(w.bossBars, pk.BossEntityUniqueID)
+		}
+	case *packet.MobEffect:
+		if pk.EntityRuntimeID != self {
+			return
+		}
+		if pk.Operation == packet.MobEffectRemove {
+			delete(w.effects, pk.EffectType)
+		} else {
+			w.effects[pk.EffectType] = struct{}{}
+		}
+	case *packet.PlayerList:
+		for _, entry := range pk.Entries {
+			if pk.ActionType == packet.PlayerListActionAdd {
+				w.playerList[entry.UUID] = struct{}{}
+			} else {
+				delete(w.playerList, entry.UUID)
+			}
+		}
+	case *packet.SetDisplayObjective:
+		w.objectives[pk.ObjectiveName] = struct{}{}
+	case *packet.RemoveObjective:
+		delete(w.objectives, pk.ObjectiveName)
+	case *packet.ModalFormRequest:
+		w.forms[pk.FormID] = struct{}{}
+		delete(w.staleForms, pk.FormID)
+	case *packet.ServerSettingsResponse:
+		w.forms[pk.FormID] = struct{}{}
+		delete(w.staleForms, pk.FormID)
+	}
+}
+
+/*
+Returns the dimension the player is in.
+*/
+func (w *worldState) dimension() int32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dim
+}
+
+func (w *worldState) setDimension(dim int32) {
+	w.mu.Lock()
+	w.dim = dim
+	w.mu.Unlock()
+}
+
+/*
+Forgets the form with the ID passed as it was answered, it returns false if the form was sent by a server the player
+was on before, so the answer must not reach the current one. Answers to forms that weren't seen are let through.
+*/
+func (w *worldState) answerForm(id uint32) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.forms, id)
+	_, stale := w.staleForms[id]
+	delete(w.staleForms, id)
+	return !stale
+}
+
+/*
+Returns the packets that remove everything the server of the player spawned and forgets it all. Forms can't be
+closed, so answers to the forms of the old server are dropped instead. Self is the runtime ID the player has on
+their client.
+*/
+func (w *worldState) reset(self uint64) []packet.Packet {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var pks []packet.Packet
+	for id := range w.bossBars {
+		pks = append(pks, &packet.BossEvent{BossEntityUniqueID: id, EventType: packet.BossEventHide})
+	}
+	for id := range w.entities {
+		pks = append(pks, &packet.RemoveActor{EntityUniqueID: id})
+	}
+	for effect := range w.effects {
+		pks = append(pks, &packet.MobEffect{EntityRuntimeID: self, Operation: packet.MobEffectRemove, EffectType: effect})
+	}
+	if len(w.playerList) > 0 {
+		list := &packet.PlayerList{ActionType: packet.PlayerListActionRemove}
+		for id := range w.playerList {
+			list.Entries = append(list.Entries, protocol.PlayerListEntry{UUID: id})
+		}
+		pks = append(pks, list)
+	}
+	for name := range w.objectives {
+		pks = append(pks, &packet.RemoveObjective{ObjectiveName: name})
+	}
+	pks = append(pks,
+		&packet.SetTitle{ActionType: packet.TitleActionClear},
+		&packet.LevelEvent{EventType: packet.EventStopRain},
+		&packet.LevelEvent{EventType: packet.EventStopThunder},
+	)
+	for id := range w.forms {
+		w.staleForms[id] = struct{}{}
+	}
+	w.clear()
+	return pks
+}
+
+/*
+Removes what the old server of a player spawned on their client, called when the player switches servers.
+*/
+func (r *Ray) resetWorld() {
+	for _, pk := range r.world.reset(r.Translations.OriginalEntityRuntimeID) {
+		_ = r.conn.WritePacket(pk)
+	}
+}
+
+/*
+Returns the dimension a player is moved to while they are transferred, it differs from both the dimension they are in
+and the one of the server they go to, as the client only loads a new world when the dimension changes.
+*/
+func intermediateDimension(current, target int32) int32 {
+	for _, dim := range []int32{packet.DimensionNether, packet.DimensionEnd, packet.DimensionOverworld} {
+		if dim != current && dim != target {
+			return dim
+		}
+	}
+	return packet.DimensionNether
+}
+
+/*
+Sends the game rules, time, world spawn, difficulty and game mode of a new server to the player, which the client
+otherwise only learns from the StartGame of the first server they joined.
+*/
+func (r *Ray) applyGameData(data minecraft.GameData) {
+	for _, pk := range gameDataPackets(data, r.Translations.OriginalEntityRuntimeID) {
+		_ = r.conn.WritePacket(pk)
+	}
+}
+
+/*
+Returns the packets that apply the game data of a server to a player, self is the runtime ID the player has on their
+client.
+*/
+func gameDataPackets(data minecraft.GameData, self uint64) []packet.Packet {
+	mode := data.PlayerGameMode
+	if mode == gameTypeWorldDefault {
+		mode = data.WorldGameMode
+	}
+	return []packet.Packet{
+		&packet.GameRulesChanged{GameRules: data.GameRules},
+		&packet.SetTime{Time: int32(data.Time)},
+		&packet.SetSpawnPosition{SpawnType: packet.SpawnTypeWorld, Position: data.WorldSpawn, Dimension: data.Dimension, SpawnPosition: data.WorldSpawn},
+		&packet.SetDifficulty{Difficulty: uint32(data.Difficulty)},
+		&packet.SetPlayerGameType{GameType: mode},
+		&packet.MovePlayer{
+			EntityRuntimeID: self,
+			Position:        data.PlayerPosition,
+			Pitch:           data.Pitch,
+			Yaw:             data.Yaw,
+			HeadYaw:         data.Yaw,
+			Mode:            packet.MoveModeReset,
+		},
+	}
+}
+
+/*
+Keeps track of what the servers spawn on the clients of the players, after any other middleware changed it.
+*/
+func (s *Sun) registerWorldState() {
+	s.Middleware.Clientbound(PriorityMonitor, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		ctx.Ray.world.track(pk, ctx.Ray.Translations.OriginalEntityRuntimeID)
+		return pk
+	})
+	s.Middleware.Serverbound(PriorityMonitor, func(ctx *PacketContext, pk packet.Packet) packet.Packet {
+		if pk, ok := pk.(*packet.ModalFormResponse); ok && !ctx.Ray.world.answerForm(pk.FormID) {
+			//the form was sent by a server the player was on before
+			return nil
+		}
+		return pk
+	})
+}
@@ -0,0 +1,151 @@
+package sun
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"testing"
+)
+
+func TestWorldState(t *testing.T) {
+	w := newWorldState()
+	id := uuid.New()
+	for _, pk := range []packet.Packet{
+		&packet.AddActor{EntityUniqueID: 5},
+		&packet.AddPlayer{EntityUniqueID: 6},
+		&packet.RemoveActor{EntityUniqueID: 6},
+		&packet.BossEvent{BossEntityUniqueID: 5, EventType: packet.BossEventShow},
+		&packet.BossEvent{BossEntityUniqueID: ProxyBossEntityID, EventType: packet.BossEventShow},
+		&packet.MobEffect{EntityRuntimeID: 1, Operation: packet.MobEffectAdd, EffectType: 3},
+		&packet.MobEffect{EntityRuntimeID: 2, Operation: packet.MobEffectAdd, EffectType: 4},
+		&packet.PlayerList{ActionType: packet.PlayerListActionAdd, Entries: []protocol.PlayerListEntry{{UUID: id}}},
+		&packet.SetDisplayObjective{ObjectiveName: "sidebar"},
+		&packet.ModalFormRequest{FormID: 7},
+	} {
+		w.track(pk, 1)
+	}
+	if !w.answerForm(7) || !w.answerForm(8) {
+		t.Fatalf("expected the answers to forms of the current server to be let through")
+	}
+
+	var hidden, removed, effects, objectives int
+	for _, pk := range w.reset(1) {
+		switch pk := pk.(type) {
+		case *packet.BossEvent:
+			if pk.BossEntityUniqueID != 5 {
+				t.Fatalf("unexpected boss bar %v hidden", pk.BossEntityUniqueID)
+			}
+			hidden++
+		case *packet.RemoveActor:
+			if pk.EntityUniqueID != 5 {
+				t.Fatalf("unexpected entity %v removed", pk.EntityUniqueID)
+			}
+			removed++
+		case *packet.MobEffect:
+			if pk.EffectType != 3 {
+				t.Fatalf("unexpected effect %v removed", pk.EffectType)
+			}
+			effects++
+		case *packet.PlayerList:
+			if len(pk.Entries) != 1 || pk.Entries[0].UUID != id {
+				t.Fatalf("unexpected player list entries %v", pk.Entries)
+			}
+		case *packet.RemoveObjective:
+			objectives++
+		}
+	}
+	if hidden != 1 || removed != 1 || effects != 1 || objectives != 1 {
+		t.Fatalf("expected one of each to be reset, got %v %v %v %v", hidden, removed, effects, objectives)
+	}
+	if pks := w.reset(1); len(pks) != 3 {
+		t.Fatalf("expected only the title and weather to be reset again, got %v packets", len(pks))
+	}
+}
+
+func TestWorldStateForms(t *testing.T) {
+	w := newWorldState()
+	w.track(&packet.ModalFormRequest{FormID: 1}, 1)
+	w.track(&packet.ServerSettingsResponse{FormID: 2}, 1)
+	w.track(&packet.ModalFormRequest{FormID: 3}, 1)
+	w.answerForm(3)
+	w.reset(1)
+
+	//the new server reuses form 1
+	w.track(&packet.ModalFormRequest{FormID: 1}, 1)
+	if !w.answerForm(1) || w.answerForm(2) || !w.answerForm(3) || !w.answerForm(4) {
+		t.Fatal("expected only the answer to the settings form of the old server to be dropped")
+	}
+	if !w.answerForm(2) {
+		t.Fatal("expected a stale form to only be dropped once")
+	}
+}
+
+func TestGameDataPackets(t *testing.T) {
+	data := minecraft.GameData{
+		Difficulty:     2,
+		PlayerGameMode: gameTypeWorldDefault,
+		WorldGameMode:  1,
+		PlayerPosition: mgl32.Vec3{1, 65, 3},
+		Yaw:            90,
+		Dimension:      packet.DimensionNether,
+		WorldSpawn:     protocol.BlockPos{0, 64, 0},
+		GameRules:      map[string]interface{}{"dodaylightcycle": false},
+		Time:           6000,
+	}
+	pks := gameDataPackets(data, 7)
+	if len(pks) != 6 {
+		t.Fatalf("expected 6 packets, got %v", len(pks))
+	}
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.GameRulesChanged:
+			if pk.GameRules["dodaylightcycle"] != false {
+				t.Fatalf("unexpected game rules %v", pk.GameRules)
+			}
+		case *packet.SetTime:
+			if pk.Time != 6000 {
+				t.Fatalf("unexpected time %v", pk.Time)
+			}
+		case *packet.SetSpawnPosition:
+			if pk.Position != data.WorldSpawn || pk.Dimension != packet.DimensionNether {
+				t.Fatalf("unexpected spawn %v in %v", pk.Position, pk.Dimension)
+			}
+		case *packet.SetDifficulty:
+			if pk.Difficulty != 2 {
+				t.Fatalf("unexpected difficulty %v", pk.Difficulty)
+			}
+		case *packet.SetPlayerGameType:
+			if pk.GameType != 1 {
+				t.Fatalf("expected the game mode of the world, got %v", pk.GameType)
+			}
+		case *packet.MovePlayer:
+			if pk.EntityRuntimeID != 7 || pk.Position != data.PlayerPosition || pk.Yaw != 90 || pk.Mode != packet.MoveModeReset {
+				t.Fatalf("unexpected move %#v", pk)
+			}
+		default:
+			t.Fatalf("unexpected packet %T", pk)
+		}
+	}
+
+	data.PlayerGameMode = 2
+	for _, pk := range gameDataPackets(data, 7) {
+		if pk, ok := pk.(*packet.SetPlayerGameType); ok && pk.GameType != 2 {
+			t.Fatalf("expected the game mode of the player, got %v", pk.GameType)
+		}
+	}
+}
+
+func TestIntermediateDimension(t *testing.T) {
+	for _, dims := range [][2]int32{
+		{packet.DimensionOverworld, packet.DimensionOverworld},
+		{packet.DimensionNether, packet.DimensionOverworld},
+		{packet.DimensionOverworld, packet.DimensionNether},
+		{packet.DimensionEnd, packet.DimensionNether},
+	} {
+		if dim := intermediateDimension(dims[0], dims[1]); dim == dims[0] || dim == dims[1] {
+			t.Fatalf("expected the dimension between %v and %v to differ from both, got %v", dims[0], dims[1], dim)
+		}
+	}
+}